@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/itzloop/1brc/internal/bench"
+	"github.com/itzloop/1brc/internal/processors"
+)
+
+// runBench is the bench subcommand, our evaluate.sh. Every run is a fresh
+// process, like evaluate.sh starts a fresh JVM, so runs don't share a heap
+// and each one has its own peak RSS.
+func runBench(args []string) error {
+	cfg := processors.DefaultConfig()
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	runs := fs.Int("runs", 5, "number of runs, the fastest and slowest are left out of the stats when there are at least 3")
+	jsonOut := fs.Bool("json", false, "print the report as JSON")
+	inputPath := fs.String("i", "measurements.txt", "path to input file, glob or directory. More inputs can follow the flags")
+	processorName := fs.String("processor", "parallel-read", fmt.Sprintf("processor to run, one of: %s", strings.Join(processors.Names(), "|")))
+	configFlags(fs, &cfg)
+	stationFlags(fs) // only forwarded to the runs
+	fs.Parse(args)
+
+	paths, err := processors.ExpandPaths(append([]string{*inputPath}, fs.Args()...)...)
+	if err != nil {
+		return err
+	}
+
+	// the processor config flags that were set go to every run as is
+	runArgs := []string{"bench-run", "-processor", *processorName}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "runs", "json", "i", "processor":
+			return
+		}
+		runArgs = append(runArgs, fmt.Sprintf("-%s=%s", f.Name, f.Value))
+	})
+	runArgs = append(runArgs, "--")
+	runArgs = append(runArgs, paths...)
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the 1brc binary: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report := bench.Report{
+		Processor: *processorName,
+		Inputs:    paths,
+		Time:      time.Now().UTC().Format(time.RFC3339),
+		GoVersion: runtime.Version(),
+	}
+
+	for i := 0; i < *runs; i++ {
+		cmd := exec.CommandContext(ctx, exe, runArgs...)
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return fmt.Errorf("run %d failed: %w", i+1, err)
+		}
+
+		var run bench.Run
+		if err := json.Unmarshal(out, &run); err != nil {
+			return fmt.Errorf("run %d printed something that is not a run: %w", i+1, err)
+		}
+		run.PeakRSS = bench.PeakRSS(cmd.ProcessState)
+
+		log.Printf("run %d: %s, %d gc, %.1f MB peak rss\n", i+1, run.Wall, run.NumGC, float64(run.PeakRSS)/1e6)
+		report.Runs = append(report.Runs, run)
+	}
+
+	report.Summarize()
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	fmt.Printf("%s: %d runs, stats over %d\n", report.Processor, len(report.Runs), report.Kept)
+	fmt.Printf("wall:       %.3fs ± %.3fs\n", report.MeanWall, report.StddevWall)
+	fmt.Printf("throughput: %.1f MB/s, %.2fM rows/s\n", report.MBPerSec, report.RowsPerSec/1e6)
+	fmt.Printf("gc:         %.1f cycles, %.3fms pause\n", report.MeanNumGC, report.MeanPause*1000)
+	fmt.Printf("peak rss:   %.1f MB\n", float64(report.PeakRSS)/1e6)
+
+	return nil
+}
+
+// runBenchRun is the bench-run subcommand runBench starts for every run. It
+// processes the files given as arguments once and prints a bench.Run as
+// JSON.
+func runBenchRun(args []string) error {
+	cfg := processors.DefaultConfig()
+	fs := flag.NewFlagSet("bench-run", flag.ExitOnError)
+	processorName := fs.String("processor", "parallel-read", "processor to run")
+	configFlags(fs, &cfg)
+	stationFilter := stationFlags(fs)
+	fs.Parse(args)
+
+	var err error
+	cfg.Stations, err = stationFilter()
+	if err != nil {
+		return err
+	}
+
+	processor, err := processors.New(*processorName, cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	result, err := processors.ProcessFiles(ctx, processor, fs.Args(), false)
+	if err != nil {
+		return err
+	}
+
+	wall := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	return json.NewEncoder(os.Stdout).Encode(bench.Run{
+		Wall:    wall,
+		Rows:    result.Rows,
+		Bytes:   result.Bytes,
+		NumGC:   after.NumGC - before.NumGC,
+		GCPause: time.Duration(after.PauseTotalNs - before.PauseTotalNs),
+	})
+}
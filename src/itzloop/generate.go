@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"runtime"
+	"time"
+
+	"github.com/itzloop/1brc/constants"
+	"github.com/itzloop/1brc/internal/generator"
+)
+
+// generate is the generate subcommand, a Go version of create_measurements.sh.
+func generate(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	rows := fs.Int64("n", 1_000_000_000, "number of measurements to write")
+	out := fs.String("o", "measurements.txt", "file to write, - writes to stdout")
+	seed := fs.Uint64("seed", 0, "seed of the random source, 0 picks one and logs it")
+	writers := fs.Int("writers", runtime.NumCPU(), "number of goroutines generating measurements")
+	stations := fs.Int("stations", len(generator.Stations), "number of unique stations, up to 10000 gives stations past the first 413 numbered names")
+	fs.Parse(args)
+
+	if *seed == 0 {
+		*seed = rand.Uint64()
+	}
+	log.Printf("generating %d measurements with seed %d\n", *rows, *seed)
+
+	opts := generator.Options{
+		Rows:    *rows,
+		Seed:    *seed,
+		Writers: *writers,
+	}
+
+	var err error
+	opts.Stations, err = generator.UniqueStations(*stations)
+	if err != nil {
+		return err
+	}
+
+	f := os.Stdout
+	if *out != "-" {
+		f, err = os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("failed to create file: %w", err)
+		}
+		defer f.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	start := time.Now()
+	bw := bufio.NewWriterSize(f, 4*constants.MiB)
+	if err := generator.Generate(ctx, bw, opts); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write measurements: %w", err)
+	}
+
+	if f != os.Stdout {
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("failed to close file: %w", err)
+		}
+	}
+
+	log.Printf("created file with %d measurements in %s\n", *rows, time.Since(start))
+	return nil
+}
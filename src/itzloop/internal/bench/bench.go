@@ -0,0 +1,95 @@
+// Package bench summarizes repeated runs of a processor the way evaluate.sh
+// does for the Java forks, minus the eyeballing.
+package bench
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"time"
+)
+
+// Run is what a single run of a processor measured.
+type Run struct {
+	Wall  time.Duration `json:"wall"`
+	Rows  int64         `json:"rows"`
+	Bytes int64         `json:"bytes"`
+
+	// NumGC and GCPause are the garbage collections that happened during
+	// the run and how long they stopped the world for in total.
+	NumGC   uint32        `json:"num_gc"`
+	GCPause time.Duration `json:"gc_pause"`
+
+	// PeakRSS is the maximum resident set size of the process that did the
+	// run, in bytes. It is 0 where we can't tell.
+	PeakRSS int64 `json:"peak_rss"`
+}
+
+// Report sums up the runs of one processor. Wall times are in seconds.
+type Report struct {
+	Processor string   `json:"processor"`
+	Inputs    []string `json:"inputs"`
+	Time      string   `json:"time"`
+	GoVersion string   `json:"go_version"`
+
+	Runs []Run `json:"runs"`
+
+	// Kept is the number of runs the numbers below are computed from, the
+	// fastest and slowest runs are dropped when there are at least three.
+	Kept       int     `json:"kept"`
+	MeanWall   float64 `json:"mean_wall_seconds"`
+	StddevWall float64 `json:"stddev_wall_seconds"`
+	MBPerSec   float64 `json:"mb_per_sec"`
+	RowsPerSec float64 `json:"rows_per_sec"`
+	MeanNumGC  float64 `json:"mean_num_gc"`
+	MeanPause  float64 `json:"mean_gc_pause_seconds"`
+	PeakRSS    int64   `json:"peak_rss"`
+}
+
+// Summarize fills in the statistics of r from r.Runs.
+func (r *Report) Summarize() {
+	kept := slices.Clone(r.Runs)
+	slices.SortFunc(kept, func(a, b Run) int {
+		return cmp.Compare(a.Wall, b.Wall)
+	})
+	if len(kept) >= 3 {
+		kept = kept[1 : len(kept)-1]
+	}
+
+	r.Kept = len(kept)
+	if r.Kept == 0 {
+		return
+	}
+
+	var wall, pause, numGC, bytes, rows float64
+	for _, run := range kept {
+		wall += run.Wall.Seconds()
+		pause += run.GCPause.Seconds()
+		numGC += float64(run.NumGC)
+		bytes += float64(run.Bytes)
+		rows += float64(run.Rows)
+	}
+
+	n := float64(r.Kept)
+	r.MeanWall = wall / n
+	r.MeanPause = pause / n
+	r.MeanNumGC = numGC / n
+	if wall > 0 {
+		r.MBPerSec = bytes / 1e6 / wall
+		r.RowsPerSec = rows / wall
+	}
+
+	if r.Kept > 1 {
+		var sq float64
+		for _, run := range kept {
+			d := run.Wall.Seconds() - r.MeanWall
+			sq += d * d
+		}
+		r.StddevWall = math.Sqrt(sq / (n - 1))
+	}
+
+	r.PeakRSS = 0
+	for _, run := range r.Runs {
+		r.PeakRSS = max(r.PeakRSS, run.PeakRSS)
+	}
+}
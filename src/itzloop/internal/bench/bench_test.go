@@ -0,0 +1,60 @@
+package bench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarize(t *testing.T) {
+	run := func(wall time.Duration, rss int64) Run {
+		return Run{Wall: wall, Rows: 1_000, Bytes: 10_000_000, NumGC: 2, GCPause: time.Millisecond, PeakRSS: rss}
+	}
+
+	t.Run("drops fastest and slowest", func(t *testing.T) {
+		r := Report{Runs: []Run{
+			run(5*time.Second, 10),
+			run(1*time.Second, 20),
+			run(2*time.Second, 30),
+			run(4*time.Second, 40),
+			run(3*time.Second, 50),
+		}}
+		r.Summarize()
+
+		assert.Equal(t, 3, r.Kept)
+		assert.InDelta(t, 3.0, r.MeanWall, 1e-9)
+		assert.InDelta(t, 1.0, r.StddevWall, 1e-9)
+		assert.InDelta(t, 10.0/3, r.MBPerSec, 1e-9)
+		assert.InDelta(t, 1000.0/3, r.RowsPerSec, 1e-9)
+		assert.InDelta(t, 2.0, r.MeanNumGC, 1e-9)
+		assert.InDelta(t, 0.001, r.MeanPause, 1e-9)
+		assert.EqualValues(t, 50, r.PeakRSS, "peak rss is over every run")
+		assert.Equal(t, 5*time.Second, r.Runs[0].Wall, "runs keep their order")
+	})
+
+	t.Run("too few runs to drop any", func(t *testing.T) {
+		r := Report{Runs: []Run{run(1*time.Second, 0), run(3*time.Second, 0)}}
+		r.Summarize()
+
+		assert.Equal(t, 2, r.Kept)
+		assert.InDelta(t, 2.0, r.MeanWall, 1e-9)
+		assert.InDelta(t, 1.4142135, r.StddevWall, 1e-6)
+	})
+
+	t.Run("single run", func(t *testing.T) {
+		r := Report{Runs: []Run{run(2*time.Second, 0)}}
+		r.Summarize()
+
+		assert.Equal(t, 1, r.Kept)
+		assert.InDelta(t, 2.0, r.MeanWall, 1e-9)
+		assert.Zero(t, r.StddevWall)
+	})
+
+	t.Run("no runs", func(t *testing.T) {
+		r := Report{}
+		r.Summarize()
+		assert.Zero(t, r.Kept)
+		assert.Zero(t, r.MeanWall)
+	})
+}
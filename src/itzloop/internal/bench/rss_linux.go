@@ -0,0 +1,17 @@
+package bench
+
+import (
+	"os"
+	"syscall"
+)
+
+// PeakRSS returns the maximum resident set size of the exited process ps, in
+// bytes.
+func PeakRSS(ps *os.ProcessState) int64 {
+	ru, ok := ps.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+
+	return ru.Maxrss * 1024 // kilobytes on linux
+}
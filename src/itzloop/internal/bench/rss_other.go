@@ -0,0 +1,10 @@
+//go:build !linux
+
+package bench
+
+import "os"
+
+// PeakRSS returns 0, we only know how to get the peak RSS on linux.
+func PeakRSS(ps *os.ProcessState) int64 {
+	return 0
+}
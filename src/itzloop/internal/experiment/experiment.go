@@ -0,0 +1,303 @@
+// Package experiment writes the profiles of a run, together with what was
+// run and how long it took, into a directory of its own so runs can be
+// compared later without moving files around by hand.
+package experiment
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"runtime/trace"
+	"strings"
+	"time"
+)
+
+const (
+	// mutexProfileFraction and blockProfileRate are only set while an
+	// experiment with mutex or block profiles runs. One in 10 contention
+	// events and one blocking event per microsecond spent blocked is enough
+	// to see the channel waits without slowing the workers down much.
+	mutexProfileFraction = 10
+	blockProfileRate     = int(time.Microsecond)
+)
+
+// Profiles selects the profiles an experiment writes.
+type Profiles struct {
+	CPU   bool
+	Heap  bool
+	Trace bool
+	Mutex bool
+	Block bool
+}
+
+// Any reports whether any profile is selected.
+func (p Profiles) Any() bool {
+	return p.CPU || p.Heap || p.Trace || p.Mutex || p.Block
+}
+
+// All returns every profile.
+func All() Profiles {
+	return Profiles{CPU: true, Heap: true, Trace: true, Mutex: true, Block: true}
+}
+
+// Input is an input file and its size in bytes.
+type Input struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// Inputs stats every path. Paths that can't be stat'ed, like stdin, are
+// reported with a size of -1.
+func Inputs(paths []string) []Input {
+	inputs := make([]Input, 0, len(paths))
+	for _, p := range paths {
+		size := int64(-1)
+		if fInfo, err := os.Stat(p); err == nil {
+			size = fInfo.Size()
+		}
+		inputs = append(inputs, Input{Path: p, Size: size})
+	}
+
+	return inputs
+}
+
+// Meta is written to meta.json in the experiment directory.
+type Meta struct {
+	Experiment string   `json:"experiment"`
+	Processor  string   `json:"processor"`
+	Config     any      `json:"config"`
+	Args       []string `json:"args"`
+	Inputs     []Input  `json:"inputs"`
+
+	GitRevision string `json:"git_revision"`
+	GitModified bool   `json:"git_modified"`
+	GoVersion   string `json:"go_version"`
+	GOMAXPROCS  int    `json:"gomaxprocs"`
+	NumCPU      int    `json:"num_cpu"`
+
+	Start    time.Time `json:"start"`
+	Profiles []string  `json:"profiles"`
+
+	// Timings holds the duration of every timed step in seconds, total is
+	// from Start to Stop.
+	Timings map[string]float64 `json:"timings"`
+
+	// Stats is what the processor reported about the run, if anything.
+	Stats any `json:"stats,omitempty"`
+
+	// Error is why the run failed, empty if it didn't.
+	Error string `json:"error,omitempty"`
+}
+
+// Experiment is a run being profiled.
+type Experiment struct {
+	// Dir is where the profiles and meta.json end up.
+	Dir  string
+	Meta Meta
+
+	profiles  Profiles
+	cpu       *os.File
+	trace     *os.File
+	prevMutex int
+}
+
+// Start creates root/name/<start time> and starts the selected profiles. Runs
+// started in the same second get a -2, -3, ... suffix instead of sharing a
+// directory. The run related fields of meta are filled in, the rest is up to
+// the caller.
+func Start(root, name string, profiles Profiles, meta Meta) (*Experiment, error) {
+	start := time.Now()
+	dir, err := mkdirUnique(filepath.Join(root, name, start.Format("2006-01-02T15-04-05")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create experiment directory: %w", err)
+	}
+
+	meta.Experiment = name
+	meta.Start = start
+	meta.GitRevision, meta.GitModified = gitRevision()
+	meta.GoVersion = runtime.Version()
+	meta.GOMAXPROCS = runtime.GOMAXPROCS(0)
+	meta.NumCPU = runtime.NumCPU()
+	meta.Timings = map[string]float64{}
+
+	e := &Experiment{Dir: dir, Meta: meta, profiles: profiles}
+
+	if profiles.CPU {
+		f, err := os.Create(filepath.Join(dir, "cpu.pb.gz"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cpu profile: %w", err)
+		}
+
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to start cpu profiler: %w", err)
+		}
+		e.cpu = f
+		e.Meta.Profiles = append(e.Meta.Profiles, "cpu")
+	}
+
+	if profiles.Trace {
+		f, err := os.Create(filepath.Join(dir, "trace.out"))
+		if err != nil {
+			e.stopCPU()
+			return nil, fmt.Errorf("failed to create trace: %w", err)
+		}
+
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			e.stopCPU()
+			return nil, fmt.Errorf("failed to start tracer: %w", err)
+		}
+		e.trace = f
+		e.Meta.Profiles = append(e.Meta.Profiles, "trace")
+	}
+
+	if profiles.Heap {
+		e.Meta.Profiles = append(e.Meta.Profiles, "heap")
+	}
+
+	if profiles.Mutex {
+		e.prevMutex = runtime.SetMutexProfileFraction(mutexProfileFraction)
+		e.Meta.Profiles = append(e.Meta.Profiles, "mutex")
+	}
+
+	if profiles.Block {
+		runtime.SetBlockProfileRate(blockProfileRate)
+		e.Meta.Profiles = append(e.Meta.Profiles, "block")
+	}
+
+	return e, nil
+}
+
+// Time records d as the duration of the step called name.
+func (e *Experiment) Time(name string, d time.Duration) {
+	e.Meta.Timings[name] = d.Seconds()
+}
+
+// Stop stops the running profiles, writes the heap, mutex and block
+// profiles and finally meta.json.
+func (e *Experiment) Stop() error {
+	e.Time("total", time.Since(e.Meta.Start))
+
+	var errs []error
+	errs = append(errs, e.stopCPU())
+
+	if e.trace != nil {
+		trace.Stop()
+		errs = append(errs, e.trace.Close())
+	}
+
+	if e.profiles.Heap {
+		runtime.GC() // the heap profile is as of the last gc
+		errs = append(errs, e.writeProfile("heap", "heap.pb.gz"))
+	}
+
+	if e.profiles.Mutex {
+		errs = append(errs, e.writeProfile("mutex", "mutex.pb.gz"))
+		runtime.SetMutexProfileFraction(e.prevMutex)
+	}
+
+	if e.profiles.Block {
+		errs = append(errs, e.writeProfile("block", "block.pb.gz"))
+		runtime.SetBlockProfileRate(0)
+	}
+
+	meta, err := json.MarshalIndent(e.Meta, "", "  ")
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to encode meta: %w", err))
+	} else {
+		errs = append(errs, os.WriteFile(filepath.Join(e.Dir, "meta.json"), append(meta, '\n'), 0o644))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Fail is Stop for a run that failed with err, the error ends up in
+// meta.json next to whatever was profiled until then.
+func (e *Experiment) Fail(err error) error {
+	e.Meta.Error = err.Error()
+	return e.Stop()
+}
+
+func (e *Experiment) stopCPU() error {
+	if e.cpu == nil {
+		return nil
+	}
+
+	pprof.StopCPUProfile()
+	err := e.cpu.Close()
+	e.cpu = nil
+	return err
+}
+
+func (e *Experiment) writeProfile(profile, name string) error {
+	f, err := os.Create(filepath.Join(e.Dir, name))
+	if err != nil {
+		return fmt.Errorf("failed to create %s profile: %w", profile, err)
+	}
+	defer f.Close()
+
+	if err := pprof.Lookup(profile).WriteTo(f, 0); err != nil {
+		return fmt.Errorf("failed to write %s profile: %w", profile, err)
+	}
+
+	return f.Close()
+}
+
+// mkdirUnique creates dir, or dir-2, dir-3, ... if it already exists, and
+// returns the one it created.
+func mkdirUnique(dir string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return "", err
+	}
+
+	name := dir
+	for i := 2; ; i++ {
+		err := os.Mkdir(name, 0o755)
+		if err == nil {
+			return name, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", err
+		}
+		name = fmt.Sprintf("%s-%d", dir, i)
+	}
+}
+
+// gitRevision returns the commit the binary was built from and whether the
+// tree had changes. go run doesn't stamp builds, so fall back to asking git
+// about the working directory.
+func gitRevision() (string, bool) {
+	var rev string
+	var modified bool
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				rev = s.Value
+			case "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+	}
+	if rev != "" {
+		return rev, modified
+	}
+
+	out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", false
+	}
+	rev = strings.TrimSpace(string(out))
+
+	out, err = exec.Command("git", "status", "--porcelain").Output()
+	return rev, err == nil && len(bytes.TrimSpace(out)) > 0
+}
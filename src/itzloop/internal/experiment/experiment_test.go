@@ -0,0 +1,111 @@
+package experiment
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExperiment(t *testing.T) {
+	root := t.TempDir()
+	input := filepath.Join(root, "measurements.txt")
+	require.NoError(t, os.WriteFile(input, []byte("Hamburg;12.0\n"), 0o644))
+
+	e, err := Start(root, "01_test", All(), Meta{
+		Processor: "naive",
+		Config:    map[string]int{"Processors": 2},
+		Inputs:    Inputs([]string{input, "-"}),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "01_test"), filepath.Dir(e.Dir))
+
+	e.Time("process", 2*time.Second)
+	require.NoError(t, e.Stop())
+
+	for _, name := range []string{"cpu.pb.gz", "heap.pb.gz", "trace.out", "mutex.pb.gz", "block.pb.gz"} {
+		fInfo, err := os.Stat(filepath.Join(e.Dir, name))
+		require.NoError(t, err)
+		assert.NotZero(t, fInfo.Size(), name)
+	}
+
+	data, err := os.ReadFile(filepath.Join(e.Dir, "meta.json"))
+	require.NoError(t, err)
+
+	var meta Meta
+	require.NoError(t, json.Unmarshal(data, &meta))
+	assert.Equal(t, "01_test", meta.Experiment)
+	assert.Equal(t, "naive", meta.Processor)
+	assert.Equal(t, map[string]any{"Processors": 2.0}, meta.Config)
+	assert.Equal(t, []Input{{Path: input, Size: 13}, {Path: "-", Size: -1}}, meta.Inputs)
+	assert.Equal(t, []string{"cpu", "trace", "heap", "mutex", "block"}, meta.Profiles)
+	assert.Equal(t, 2.0, meta.Timings["process"])
+	assert.Contains(t, meta.Timings, "total")
+}
+
+func TestExperimentOnlySomeProfiles(t *testing.T) {
+	e, err := Start(t.TempDir(), "heap", Profiles{Heap: true}, Meta{})
+	require.NoError(t, err)
+	require.NoError(t, e.Stop())
+
+	entries, err := os.ReadDir(e.Dir)
+	require.NoError(t, err)
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"heap.pb.gz", "meta.json"}, names)
+}
+
+func TestExperimentFail(t *testing.T) {
+	e, err := Start(t.TempDir(), "fail", Profiles{CPU: true, Mutex: true}, Meta{})
+	require.NoError(t, err)
+	require.NoError(t, e.Fail(errors.New("failed to parse line 3")))
+
+	// the profilers are stopped and the mutex rate is back to what it was
+	require.NoError(t, pprof.StartCPUProfile(io.Discard))
+	pprof.StopCPUProfile()
+	assert.Zero(t, runtime.SetMutexProfileFraction(-1))
+
+	data, err := os.ReadFile(filepath.Join(e.Dir, "meta.json"))
+	require.NoError(t, err)
+
+	var meta Meta
+	require.NoError(t, json.Unmarshal(data, &meta))
+	assert.Equal(t, "failed to parse line 3", meta.Error)
+	assert.Contains(t, meta.Timings, "total")
+	assert.FileExists(t, filepath.Join(e.Dir, "mutex.pb.gz"))
+}
+
+func TestExperimentSameSecond(t *testing.T) {
+	root := t.TempDir()
+
+	dirs := map[string]bool{}
+	for range 3 {
+		e, err := Start(root, "same", Profiles{Heap: true}, Meta{})
+		require.NoError(t, err)
+		require.NoError(t, e.Stop())
+		dirs[e.Dir] = true
+	}
+	assert.Len(t, dirs, 3)
+
+	dir := filepath.Join(root, "taken")
+	for _, want := range []string{dir, dir + "-2", dir + "-3"} {
+		got, err := mkdirUnique(dir)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	for dir := range dirs {
+		assert.FileExists(t, filepath.Join(dir, "meta.json"))
+	}
+}
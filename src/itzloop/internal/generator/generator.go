@@ -0,0 +1,162 @@
+// Package generator writes measurement files the way CreateMeasurements.java
+// does, so test data can be made without a JVM.
+package generator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"strconv"
+)
+
+// Station is a weather station and the mean temperature its measurements are
+// drawn around.
+type Station struct {
+	Name string
+	Mean float64
+}
+
+// maxStations is the number of unique station names the challenge allows.
+const maxStations = 10_000
+
+// UniqueStations returns n stations with distinct names. The first ones come
+// from Stations, past those every station gets a numbered copy, "Hamburg 2",
+// "Hamburg 3" and so on, with the mean temperature of the original.
+func UniqueStations(n int) ([]Station, error) {
+	if n <= 0 || n > maxStations {
+		return nil, fmt.Errorf("number of stations must be between 1 and %d, got %d", maxStations, n)
+	}
+
+	stations := make([]Station, 0, n)
+	for i := 0; i < n; i++ {
+		s := Stations[i%len(Stations)]
+		if round := i / len(Stations); round > 0 {
+			s.Name += " " + strconv.Itoa(round+1)
+		}
+		stations = append(stations, s)
+	}
+
+	return stations, nil
+}
+
+// Options controls what Generate writes.
+type Options struct {
+	// Rows is the number of measurements to write.
+	Rows int64
+
+	// Seed makes the output reproducible, the same seed and Stations always
+	// give the same bytes no matter how many Writers there are.
+	Seed uint64
+
+	// Writers is the number of goroutines generating rows, defaults to the
+	// number of CPUs.
+	Writers int
+
+	// Stations to pick from, defaults to Stations.
+	Stations []Station
+
+	// BlockRows is the number of rows each writer generates at a time.
+	BlockRows int
+}
+
+// Generate writes opts.Rows lines of "station;measurement" to w. A station is
+// picked uniformly at random and its measurement drawn from a normal
+// distribution around its mean with a standard deviation of 10, rounded to
+// one fractional digit and kept within the [-99.9, 99.9] the challenge
+// allows.
+//
+// Blocks of rows are generated in parallel, each with its own random source
+// seeded from Seed and the block number, and written to w in order.
+func Generate(ctx context.Context, w io.Writer, opts Options) error {
+	if opts.Writers <= 0 {
+		opts.Writers = runtime.NumCPU()
+	}
+	if len(opts.Stations) == 0 {
+		opts.Stations = Stations
+	}
+	if opts.BlockRows <= 0 {
+		opts.BlockRows = 1 << 20
+	}
+
+	var (
+		blocks = (opts.Rows + int64(opts.BlockRows) - 1) / int64(opts.BlockRows)
+		order  = make(chan chan []byte, opts.Writers)
+		free   = make(chan []byte, opts.Writers+1)
+		stop   = make(chan struct{})
+	)
+
+	go func() {
+		defer close(order)
+		for i := int64(0); i < blocks; i++ {
+			fut := make(chan []byte, 1)
+			select {
+			case order <- fut:
+			case <-stop:
+				return
+			}
+
+			rows := min(int64(opts.BlockRows), opts.Rows-i*int64(opts.BlockRows))
+			go func(i, rows int64) {
+				var buf []byte
+				select {
+				case buf = <-free:
+				default:
+				}
+
+				fut <- generateBlock(buf[:0], opts.Stations, opts.Seed, i, rows)
+			}(i, rows)
+		}
+	}()
+
+	// wait for every block in flight before returning so nothing keeps
+	// running after an error.
+	defer func() {
+		close(stop)
+		for fut := range order {
+			<-fut
+		}
+	}()
+
+	for fut := range order {
+		buf := <-fut
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if _, err := w.Write(buf); err != nil {
+			return fmt.Errorf("failed to write measurements: %w", err)
+		}
+
+		select {
+		case free <- buf:
+		default:
+		}
+	}
+
+	return ctx.Err()
+}
+
+func generateBlock(buf []byte, stations []Station, seed uint64, block, rows int64) []byte {
+	rng := rand.New(rand.NewPCG(seed, uint64(block)))
+	for i := int64(0); i < rows; i++ {
+		s := &stations[rng.IntN(len(stations))]
+
+		// Math.round(m * 10.0) / 10.0 like CreateMeasurements.java, in tenths
+		m := int64(math.Floor((rng.NormFloat64()*10+s.Mean)*10 + 0.5))
+		m = max(min(m, 999), -999)
+
+		buf = append(buf, s.Name...)
+		buf = append(buf, ';')
+		if m < 0 {
+			buf = append(buf, '-')
+			m = -m
+		}
+		buf = strconv.AppendInt(buf, m/10, 10)
+		buf = append(buf, '.', byte('0'+m%10), '\n')
+	}
+
+	return buf
+}
@@ -0,0 +1,143 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itzloop/1brc/utils"
+)
+
+func TestStations(t *testing.T) {
+	assert.Len(t, Stations, 413)
+
+	seen := map[string]bool{}
+	for _, s := range Stations {
+		assert.False(t, seen[s.Name], s.Name)
+		seen[s.Name] = true
+	}
+
+	assert.Equal(t, Station{Name: "Abha", Mean: 18.0}, Stations[0])
+	assert.Equal(t, Station{Name: "Zürich", Mean: 9.3}, Stations[len(Stations)-1])
+}
+
+func TestUniqueStations(t *testing.T) {
+	stations, err := UniqueStations(10_000)
+	require.NoError(t, err)
+	require.Len(t, stations, 10_000)
+
+	seen := map[string]bool{}
+	for _, s := range stations {
+		assert.False(t, seen[s.Name], s.Name)
+		seen[s.Name] = true
+
+		assert.True(t, utf8.ValidString(s.Name), s.Name)
+		assert.LessOrEqual(t, len(s.Name), 100, s.Name)
+		assert.NotContains(t, s.Name, ";")
+	}
+	assert.Equal(t, Station{Name: "Abha 2", Mean: 18.0}, stations[len(Stations)])
+
+	stations, err = UniqueStations(10)
+	require.NoError(t, err)
+	assert.Equal(t, Stations[:10], stations)
+
+	_, err = UniqueStations(0)
+	assert.Error(t, err)
+	_, err = UniqueStations(10_001)
+	assert.Error(t, err)
+}
+
+func TestGenerate(t *testing.T) {
+	generate := func(opts Options) []byte {
+		t.Helper()
+
+		var buf bytes.Buffer
+		require.NoError(t, Generate(context.Background(), &buf, opts))
+		return buf.Bytes()
+	}
+
+	t.Run("rows are valid", func(t *testing.T) {
+		data := generate(Options{Rows: 10_007, Seed: 1, BlockRows: 1000})
+
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		require.Len(t, lines, 10_007)
+
+		names := map[string]bool{}
+		for _, s := range Stations {
+			names[s.Name] = true
+		}
+
+		for _, line := range lines {
+			station, measure, ok := strings.Cut(line, ";")
+			require.True(t, ok, line)
+			assert.True(t, names[station], line)
+
+			_, err := utils.ParseTenths([]byte(measure))
+			assert.NoError(t, err, line)
+		}
+	})
+
+	t.Run("same seed same output", func(t *testing.T) {
+		opts := Options{Rows: 5_000, Seed: 42, BlockRows: 512, Writers: 1}
+		expected := generate(opts)
+
+		opts.Writers = 8
+		assert.Equal(t, expected, generate(opts))
+
+		opts.Seed = 43
+		assert.NotEqual(t, expected, generate(opts))
+	})
+
+	t.Run("distribution", func(t *testing.T) {
+		stations := []Station{{Name: "a", Mean: 12.3}}
+		data := generate(Options{Rows: 100_000, Seed: 7, Stations: stations})
+
+		var sum, sumSq float64
+		lines := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+		for _, line := range lines {
+			m, err := utils.ParseTenths(line[2:])
+			require.NoError(t, err)
+
+			v := float64(m) / 10
+			sum += v
+			sumSq += v * v
+		}
+
+		n := float64(len(lines))
+		mean := sum / n
+		assert.InDelta(t, 12.3, mean, 0.2)
+		assert.InDelta(t, 10, math.Sqrt(sumSq/n-mean*mean), 0.2)
+	})
+
+	t.Run("no rows", func(t *testing.T) {
+		assert.Empty(t, generate(Options{Rows: 0, Seed: 1}))
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := Generate(ctx, &bytes.Buffer{}, Options{Rows: 100_000, BlockRows: 100})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		err := Generate(context.Background(), failingWriter{}, Options{Rows: 100_000, BlockRows: 100})
+		assert.ErrorIs(t, err, errWrite)
+	})
+}
+
+var errWrite = errors.New("disk full")
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errWrite
+}
@@ -0,0 +1,419 @@
+package generator
+
+// Stations are the weather stations and mean temperatures that
+// CreateMeasurements.java picks from, in the same order.
+var Stations = []Station{
+	{Name: "Abha", Mean: 18.0},
+	{Name: "Abidjan", Mean: 26.0},
+	{Name: "Abéché", Mean: 29.4},
+	{Name: "Accra", Mean: 26.4},
+	{Name: "Addis Ababa", Mean: 16.0},
+	{Name: "Adelaide", Mean: 17.3},
+	{Name: "Aden", Mean: 29.1},
+	{Name: "Ahvaz", Mean: 25.4},
+	{Name: "Albuquerque", Mean: 14.0},
+	{Name: "Alexandra", Mean: 11.0},
+	{Name: "Alexandria", Mean: 20.0},
+	{Name: "Algiers", Mean: 18.2},
+	{Name: "Alice Springs", Mean: 21.0},
+	{Name: "Almaty", Mean: 10.0},
+	{Name: "Amsterdam", Mean: 10.2},
+	{Name: "Anadyr", Mean: -6.9},
+	{Name: "Anchorage", Mean: 2.8},
+	{Name: "Andorra la Vella", Mean: 9.8},
+	{Name: "Ankara", Mean: 12.0},
+	{Name: "Antananarivo", Mean: 17.9},
+	{Name: "Antsiranana", Mean: 25.2},
+	{Name: "Arkhangelsk", Mean: 1.3},
+	{Name: "Ashgabat", Mean: 17.1},
+	{Name: "Asmara", Mean: 15.6},
+	{Name: "Assab", Mean: 30.5},
+	{Name: "Astana", Mean: 3.5},
+	{Name: "Athens", Mean: 19.2},
+	{Name: "Atlanta", Mean: 17.0},
+	{Name: "Auckland", Mean: 15.2},
+	{Name: "Austin", Mean: 20.7},
+	{Name: "Baghdad", Mean: 22.77},
+	{Name: "Baguio", Mean: 19.5},
+	{Name: "Baku", Mean: 15.1},
+	{Name: "Baltimore", Mean: 13.1},
+	{Name: "Bamako", Mean: 27.8},
+	{Name: "Bangkok", Mean: 28.6},
+	{Name: "Bangui", Mean: 26.0},
+	{Name: "Banjul", Mean: 26.0},
+	{Name: "Barcelona", Mean: 18.2},
+	{Name: "Bata", Mean: 25.1},
+	{Name: "Batumi", Mean: 14.0},
+	{Name: "Beijing", Mean: 12.9},
+	{Name: "Beirut", Mean: 20.9},
+	{Name: "Belgrade", Mean: 12.5},
+	{Name: "Belize City", Mean: 26.7},
+	{Name: "Benghazi", Mean: 19.9},
+	{Name: "Bergen", Mean: 7.7},
+	{Name: "Berlin", Mean: 10.3},
+	{Name: "Bilbao", Mean: 14.7},
+	{Name: "Birao", Mean: 26.5},
+	{Name: "Bishkek", Mean: 11.3},
+	{Name: "Bissau", Mean: 27.0},
+	{Name: "Blantyre", Mean: 22.2},
+	{Name: "Bloemfontein", Mean: 15.6},
+	{Name: "Boise", Mean: 11.4},
+	{Name: "Bordeaux", Mean: 14.2},
+	{Name: "Bosaso", Mean: 30.0},
+	{Name: "Boston", Mean: 10.9},
+	{Name: "Bouaké", Mean: 26.0},
+	{Name: "Bratislava", Mean: 10.5},
+	{Name: "Brazzaville", Mean: 25.0},
+	{Name: "Bridgetown", Mean: 27.0},
+	{Name: "Brisbane", Mean: 21.4},
+	{Name: "Brussels", Mean: 10.5},
+	{Name: "Bucharest", Mean: 10.8},
+	{Name: "Budapest", Mean: 11.3},
+	{Name: "Bujumbura", Mean: 23.8},
+	{Name: "Bulawayo", Mean: 18.9},
+	{Name: "Burnie", Mean: 13.1},
+	{Name: "Busan", Mean: 15.0},
+	{Name: "Cabo San Lucas", Mean: 23.9},
+	{Name: "Cairns", Mean: 25.0},
+	{Name: "Cairo", Mean: 21.4},
+	{Name: "Calgary", Mean: 4.4},
+	{Name: "Canberra", Mean: 13.1},
+	{Name: "Cape Town", Mean: 16.2},
+	{Name: "Changsha", Mean: 17.4},
+	{Name: "Charlotte", Mean: 16.1},
+	{Name: "Chiang Mai", Mean: 25.8},
+	{Name: "Chicago", Mean: 9.8},
+	{Name: "Chihuahua", Mean: 18.6},
+	{Name: "Chișinău", Mean: 10.2},
+	{Name: "Chittagong", Mean: 25.9},
+	{Name: "Chongqing", Mean: 18.6},
+	{Name: "Christchurch", Mean: 12.2},
+	{Name: "City of San Marino", Mean: 11.8},
+	{Name: "Colombo", Mean: 27.4},
+	{Name: "Columbus", Mean: 11.7},
+	{Name: "Conakry", Mean: 26.4},
+	{Name: "Copenhagen", Mean: 9.1},
+	{Name: "Cotonou", Mean: 27.2},
+	{Name: "Cracow", Mean: 9.3},
+	{Name: "Da Lat", Mean: 17.9},
+	{Name: "Da Nang", Mean: 25.8},
+	{Name: "Dakar", Mean: 24.0},
+	{Name: "Dallas", Mean: 19.0},
+	{Name: "Damascus", Mean: 17.0},
+	{Name: "Dampier", Mean: 26.4},
+	{Name: "Dar es Salaam", Mean: 25.8},
+	{Name: "Darwin", Mean: 27.6},
+	{Name: "Denpasar", Mean: 23.7},
+	{Name: "Denver", Mean: 10.4},
+	{Name: "Detroit", Mean: 10.0},
+	{Name: "Dhaka", Mean: 25.9},
+	{Name: "Dikson", Mean: -11.1},
+	{Name: "Dili", Mean: 26.6},
+	{Name: "Djibouti", Mean: 29.9},
+	{Name: "Dodoma", Mean: 22.7},
+	{Name: "Dolisie", Mean: 24.0},
+	{Name: "Douala", Mean: 26.7},
+	{Name: "Dubai", Mean: 26.9},
+	{Name: "Dublin", Mean: 9.8},
+	{Name: "Dunedin", Mean: 11.1},
+	{Name: "Durban", Mean: 20.6},
+	{Name: "Dushanbe", Mean: 14.7},
+	{Name: "Edinburgh", Mean: 9.3},
+	{Name: "Edmonton", Mean: 4.2},
+	{Name: "El Paso", Mean: 18.1},
+	{Name: "Entebbe", Mean: 21.0},
+	{Name: "Erbil", Mean: 19.5},
+	{Name: "Erzurum", Mean: 5.1},
+	{Name: "Fairbanks", Mean: -2.3},
+	{Name: "Fianarantsoa", Mean: 17.9},
+	{Name: "Flores,  Petén", Mean: 26.4},
+	{Name: "Frankfurt", Mean: 10.6},
+	{Name: "Fresno", Mean: 17.9},
+	{Name: "Fukuoka", Mean: 17.0},
+	{Name: "Gabès", Mean: 19.5},
+	{Name: "Gaborone", Mean: 21.0},
+	{Name: "Gagnoa", Mean: 26.0},
+	{Name: "Gangtok", Mean: 15.2},
+	{Name: "Garissa", Mean: 29.3},
+	{Name: "Garoua", Mean: 28.3},
+	{Name: "George Town", Mean: 27.9},
+	{Name: "Ghanzi", Mean: 21.4},
+	{Name: "Gjoa Haven", Mean: -14.4},
+	{Name: "Guadalajara", Mean: 20.9},
+	{Name: "Guangzhou", Mean: 22.4},
+	{Name: "Guatemala City", Mean: 20.4},
+	{Name: "Halifax", Mean: 7.5},
+	{Name: "Hamburg", Mean: 9.7},
+	{Name: "Hamilton", Mean: 13.8},
+	{Name: "Hanga Roa", Mean: 20.5},
+	{Name: "Hanoi", Mean: 23.6},
+	{Name: "Harare", Mean: 18.4},
+	{Name: "Harbin", Mean: 5.0},
+	{Name: "Hargeisa", Mean: 21.7},
+	{Name: "Hat Yai", Mean: 27.0},
+	{Name: "Havana", Mean: 25.2},
+	{Name: "Helsinki", Mean: 5.9},
+	{Name: "Heraklion", Mean: 18.9},
+	{Name: "Hiroshima", Mean: 16.3},
+	{Name: "Ho Chi Minh City", Mean: 27.4},
+	{Name: "Hobart", Mean: 12.7},
+	{Name: "Hong Kong", Mean: 23.3},
+	{Name: "Honiara", Mean: 26.5},
+	{Name: "Honolulu", Mean: 25.4},
+	{Name: "Houston", Mean: 20.8},
+	{Name: "Ifrane", Mean: 11.4},
+	{Name: "Indianapolis", Mean: 11.8},
+	{Name: "Iqaluit", Mean: -9.3},
+	{Name: "Irkutsk", Mean: 1.0},
+	{Name: "Istanbul", Mean: 13.9},
+	{Name: "İzmir", Mean: 17.9},
+	{Name: "Jacksonville", Mean: 20.3},
+	{Name: "Jakarta", Mean: 26.7},
+	{Name: "Jayapura", Mean: 27.0},
+	{Name: "Jerusalem", Mean: 18.3},
+	{Name: "Johannesburg", Mean: 15.5},
+	{Name: "Jos", Mean: 22.8},
+	{Name: "Juba", Mean: 27.8},
+	{Name: "Kabul", Mean: 12.1},
+	{Name: "Kampala", Mean: 20.0},
+	{Name: "Kandi", Mean: 27.7},
+	{Name: "Kankan", Mean: 26.5},
+	{Name: "Kano", Mean: 26.4},
+	{Name: "Kansas City", Mean: 12.5},
+	{Name: "Karachi", Mean: 26.0},
+	{Name: "Karonga", Mean: 24.4},
+	{Name: "Kathmandu", Mean: 18.3},
+	{Name: "Khartoum", Mean: 29.9},
+	{Name: "Kingston", Mean: 27.4},
+	{Name: "Kinshasa", Mean: 25.3},
+	{Name: "Kolkata", Mean: 26.7},
+	{Name: "Kuala Lumpur", Mean: 27.3},
+	{Name: "Kumasi", Mean: 26.0},
+	{Name: "Kunming", Mean: 15.7},
+	{Name: "Kuopio", Mean: 3.4},
+	{Name: "Kuwait City", Mean: 25.7},
+	{Name: "Kyiv", Mean: 8.4},
+	{Name: "Kyoto", Mean: 15.8},
+	{Name: "La Ceiba", Mean: 26.2},
+	{Name: "La Paz", Mean: 23.7},
+	{Name: "Lagos", Mean: 26.8},
+	{Name: "Lahore", Mean: 24.3},
+	{Name: "Lake Havasu City", Mean: 23.7},
+	{Name: "Lake Tekapo", Mean: 8.7},
+	{Name: "Las Palmas de Gran Canaria", Mean: 21.2},
+	{Name: "Las Vegas", Mean: 20.3},
+	{Name: "Launceston", Mean: 13.1},
+	{Name: "Lhasa", Mean: 7.6},
+	{Name: "Libreville", Mean: 25.9},
+	{Name: "Lisbon", Mean: 17.5},
+	{Name: "Livingstone", Mean: 21.8},
+	{Name: "Ljubljana", Mean: 10.9},
+	{Name: "Lodwar", Mean: 29.3},
+	{Name: "Lomé", Mean: 26.9},
+	{Name: "London", Mean: 11.3},
+	{Name: "Los Angeles", Mean: 18.6},
+	{Name: "Louisville", Mean: 13.9},
+	{Name: "Luanda", Mean: 25.8},
+	{Name: "Lubumbashi", Mean: 20.8},
+	{Name: "Lusaka", Mean: 19.9},
+	{Name: "Luxembourg City", Mean: 9.3},
+	{Name: "Lviv", Mean: 7.8},
+	{Name: "Lyon", Mean: 12.5},
+	{Name: "Madrid", Mean: 15.0},
+	{Name: "Mahajanga", Mean: 26.3},
+	{Name: "Makassar", Mean: 26.7},
+	{Name: "Makurdi", Mean: 26.0},
+	{Name: "Malabo", Mean: 26.3},
+	{Name: "Malé", Mean: 28.0},
+	{Name: "Managua", Mean: 27.3},
+	{Name: "Manama", Mean: 26.5},
+	{Name: "Mandalay", Mean: 28.0},
+	{Name: "Mango", Mean: 28.1},
+	{Name: "Manila", Mean: 28.4},
+	{Name: "Maputo", Mean: 22.8},
+	{Name: "Marrakesh", Mean: 19.6},
+	{Name: "Marseille", Mean: 15.8},
+	{Name: "Maun", Mean: 22.4},
+	{Name: "Medan", Mean: 26.5},
+	{Name: "Mek'ele", Mean: 22.7},
+	{Name: "Melbourne", Mean: 15.1},
+	{Name: "Memphis", Mean: 17.2},
+	{Name: "Mexicali", Mean: 23.1},
+	{Name: "Mexico City", Mean: 17.5},
+	{Name: "Miami", Mean: 24.9},
+	{Name: "Milan", Mean: 13.0},
+	{Name: "Milwaukee", Mean: 8.9},
+	{Name: "Minneapolis", Mean: 7.8},
+	{Name: "Minsk", Mean: 6.7},
+	{Name: "Mogadishu", Mean: 27.1},
+	{Name: "Mombasa", Mean: 26.3},
+	{Name: "Monaco", Mean: 16.4},
+	{Name: "Moncton", Mean: 6.1},
+	{Name: "Monterrey", Mean: 22.3},
+	{Name: "Montreal", Mean: 6.8},
+	{Name: "Moscow", Mean: 5.8},
+	{Name: "Mumbai", Mean: 27.1},
+	{Name: "Murmansk", Mean: 0.6},
+	{Name: "Muscat", Mean: 28.0},
+	{Name: "Mzuzu", Mean: 17.7},
+	{Name: "N'Djamena", Mean: 28.3},
+	{Name: "Naha", Mean: 23.1},
+	{Name: "Nairobi", Mean: 17.8},
+	{Name: "Nakhon Ratchasima", Mean: 27.3},
+	{Name: "Napier", Mean: 14.6},
+	{Name: "Napoli", Mean: 15.9},
+	{Name: "Nashville", Mean: 15.4},
+	{Name: "Nassau", Mean: 24.6},
+	{Name: "Ndola", Mean: 20.3},
+	{Name: "New Delhi", Mean: 25.0},
+	{Name: "New Orleans", Mean: 20.7},
+	{Name: "New York City", Mean: 12.9},
+	{Name: "Ngaoundéré", Mean: 22.0},
+	{Name: "Niamey", Mean: 29.3},
+	{Name: "Nicosia", Mean: 19.7},
+	{Name: "Niigata", Mean: 13.9},
+	{Name: "Nouadhibou", Mean: 21.3},
+	{Name: "Nouakchott", Mean: 25.7},
+	{Name: "Novosibirsk", Mean: 1.7},
+	{Name: "Nuuk", Mean: -1.4},
+	{Name: "Odesa", Mean: 10.7},
+	{Name: "Odienné", Mean: 26.0},
+	{Name: "Oklahoma City", Mean: 15.9},
+	{Name: "Omaha", Mean: 10.6},
+	{Name: "Oranjestad", Mean: 28.1},
+	{Name: "Oslo", Mean: 5.7},
+	{Name: "Ottawa", Mean: 6.6},
+	{Name: "Ouagadougou", Mean: 28.3},
+	{Name: "Ouahigouya", Mean: 28.6},
+	{Name: "Ouarzazate", Mean: 18.9},
+	{Name: "Oulu", Mean: 2.7},
+	{Name: "Palembang", Mean: 27.3},
+	{Name: "Palermo", Mean: 18.5},
+	{Name: "Palm Springs", Mean: 24.5},
+	{Name: "Palmerston North", Mean: 13.2},
+	{Name: "Panama City", Mean: 28.0},
+	{Name: "Parakou", Mean: 26.8},
+	{Name: "Paris", Mean: 12.3},
+	{Name: "Perth", Mean: 18.7},
+	{Name: "Petropavlovsk-Kamchatsky", Mean: 1.9},
+	{Name: "Philadelphia", Mean: 13.2},
+	{Name: "Phnom Penh", Mean: 28.3},
+	{Name: "Phoenix", Mean: 23.9},
+	{Name: "Pittsburgh", Mean: 10.8},
+	{Name: "Podgorica", Mean: 15.3},
+	{Name: "Pointe-Noire", Mean: 26.1},
+	{Name: "Pontianak", Mean: 27.7},
+	{Name: "Port Moresby", Mean: 26.9},
+	{Name: "Port Sudan", Mean: 28.4},
+	{Name: "Port Vila", Mean: 24.3},
+	{Name: "Port-Gentil", Mean: 26.0},
+	{Name: "Portland (OR)", Mean: 12.4},
+	{Name: "Porto", Mean: 15.7},
+	{Name: "Prague", Mean: 8.4},
+	{Name: "Praia", Mean: 24.4},
+	{Name: "Pretoria", Mean: 18.2},
+	{Name: "Pyongyang", Mean: 10.8},
+	{Name: "Rabat", Mean: 17.2},
+	{Name: "Rangpur", Mean: 24.4},
+	{Name: "Reggane", Mean: 28.3},
+	{Name: "Reykjavík", Mean: 4.3},
+	{Name: "Riga", Mean: 6.2},
+	{Name: "Riyadh", Mean: 26.0},
+	{Name: "Rome", Mean: 15.2},
+	{Name: "Roseau", Mean: 26.2},
+	{Name: "Rostov-on-Don", Mean: 9.9},
+	{Name: "Sacramento", Mean: 16.3},
+	{Name: "Saint Petersburg", Mean: 5.8},
+	{Name: "Saint-Pierre", Mean: 5.7},
+	{Name: "Salt Lake City", Mean: 11.6},
+	{Name: "San Antonio", Mean: 20.8},
+	{Name: "San Diego", Mean: 17.8},
+	{Name: "San Francisco", Mean: 14.6},
+	{Name: "San Jose", Mean: 16.4},
+	{Name: "San José", Mean: 22.6},
+	{Name: "San Juan", Mean: 27.2},
+	{Name: "San Salvador", Mean: 23.1},
+	{Name: "Sana'a", Mean: 20.0},
+	{Name: "Santo Domingo", Mean: 25.9},
+	{Name: "Sapporo", Mean: 8.9},
+	{Name: "Sarajevo", Mean: 10.1},
+	{Name: "Saskatoon", Mean: 3.3},
+	{Name: "Seattle", Mean: 11.3},
+	{Name: "Ségou", Mean: 28.0},
+	{Name: "Seoul", Mean: 12.5},
+	{Name: "Seville", Mean: 19.2},
+	{Name: "Shanghai", Mean: 16.7},
+	{Name: "Singapore", Mean: 27.0},
+	{Name: "Skopje", Mean: 12.4},
+	{Name: "Sochi", Mean: 14.2},
+	{Name: "Sofia", Mean: 10.6},
+	{Name: "Sokoto", Mean: 28.0},
+	{Name: "Split", Mean: 16.1},
+	{Name: "St. John's", Mean: 5.0},
+	{Name: "St. Louis", Mean: 13.9},
+	{Name: "Stockholm", Mean: 6.6},
+	{Name: "Surabaya", Mean: 27.1},
+	{Name: "Suva", Mean: 25.6},
+	{Name: "Suwałki", Mean: 7.2},
+	{Name: "Sydney", Mean: 17.7},
+	{Name: "Tabora", Mean: 23.0},
+	{Name: "Tabriz", Mean: 12.6},
+	{Name: "Taipei", Mean: 23.0},
+	{Name: "Tallinn", Mean: 6.4},
+	{Name: "Tamale", Mean: 27.9},
+	{Name: "Tamanrasset", Mean: 21.7},
+	{Name: "Tampa", Mean: 22.9},
+	{Name: "Tashkent", Mean: 14.8},
+	{Name: "Tauranga", Mean: 14.8},
+	{Name: "Tbilisi", Mean: 12.9},
+	{Name: "Tegucigalpa", Mean: 21.7},
+	{Name: "Tehran", Mean: 17.0},
+	{Name: "Tel Aviv", Mean: 20.0},
+	{Name: "Thessaloniki", Mean: 16.0},
+	{Name: "Thiès", Mean: 24.0},
+	{Name: "Tijuana", Mean: 17.8},
+	{Name: "Timbuktu", Mean: 28.0},
+	{Name: "Tirana", Mean: 15.2},
+	{Name: "Toamasina", Mean: 23.4},
+	{Name: "Tokyo", Mean: 15.4},
+	{Name: "Toliara", Mean: 24.1},
+	{Name: "Toluca", Mean: 12.4},
+	{Name: "Toronto", Mean: 9.4},
+	{Name: "Tripoli", Mean: 20.0},
+	{Name: "Tromsø", Mean: 2.9},
+	{Name: "Tucson", Mean: 20.9},
+	{Name: "Tunis", Mean: 18.4},
+	{Name: "Ulaanbaatar", Mean: -0.4},
+	{Name: "Upington", Mean: 20.4},
+	{Name: "Ürümqi", Mean: 7.4},
+	{Name: "Vaduz", Mean: 10.1},
+	{Name: "Valencia", Mean: 18.3},
+	{Name: "Valletta", Mean: 18.8},
+	{Name: "Vancouver", Mean: 10.4},
+	{Name: "Veracruz", Mean: 25.4},
+	{Name: "Vienna", Mean: 10.4},
+	{Name: "Vientiane", Mean: 25.9},
+	{Name: "Villahermosa", Mean: 27.1},
+	{Name: "Vilnius", Mean: 6.0},
+	{Name: "Virginia Beach", Mean: 15.8},
+	{Name: "Vladivostok", Mean: 4.9},
+	{Name: "Warsaw", Mean: 8.5},
+	{Name: "Washington, D.C.", Mean: 14.6},
+	{Name: "Wau", Mean: 27.8},
+	{Name: "Wellington", Mean: 12.9},
+	{Name: "Whitehorse", Mean: -0.1},
+	{Name: "Wichita", Mean: 13.9},
+	{Name: "Willemstad", Mean: 28.0},
+	{Name: "Winnipeg", Mean: 3.0},
+	{Name: "Wrocław", Mean: 9.6},
+	{Name: "Xi'an", Mean: 14.1},
+	{Name: "Yakutsk", Mean: -8.8},
+	{Name: "Yangon", Mean: 27.5},
+	{Name: "Yaoundé", Mean: 23.8},
+	{Name: "Yellowknife", Mean: -4.3},
+	{Name: "Yerevan", Mean: 12.4},
+	{Name: "Yinchuan", Mean: 9.0},
+	{Name: "Zagreb", Mean: 10.7},
+	{Name: "Zanzibar City", Mean: 26.0},
+	{Name: "Zürich", Mean: 9.3},
+}
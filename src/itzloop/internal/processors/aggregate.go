@@ -0,0 +1,50 @@
+package processors
+
+import (
+	"github.com/itzloop/1brc/types"
+	"github.com/itzloop/1brc/utils"
+)
+
+// aggregate is what aggregators merge the maps of the workers into.
+type aggregate struct {
+	measures types.AgMeasureMap
+	dists    map[string]*types.Distribution // nil unless AgOptions asks for some
+}
+
+func newAggregate(opts types.AgOptions) *aggregate {
+	a := &aggregate{measures: types.AgMeasureMap{}}
+	if opts.Any() {
+		a.dists = map[string]*types.Distribution{}
+	}
+
+	return a
+}
+
+// merge adds every station of a worker map to a.
+func (a *aggregate) merge(local *utils.CustomMap) {
+	local.Range(func(k []byte, v *types.AgMeasures, d *types.Distribution) bool {
+		agM, ok := a.measures[string(k)]
+		if !ok {
+			agM = types.NewAgMeasures()
+			a.measures[string(k)] = agM
+		}
+		agM.Merge(v)
+
+		if d != nil {
+			agD, ok := a.dists[string(k)]
+			if !ok {
+				agD = &types.Distribution{}
+				a.dists[string(k)] = agD
+			}
+			agD.Merge(d)
+		}
+		return true
+	})
+}
+
+// result returns a as the Result of an input of size bytes.
+func (a *aggregate) result(bytes int64) *types.Result {
+	r := types.NewResult(a.measures, bytes)
+	r.Distributions = a.dists
+	return r
+}
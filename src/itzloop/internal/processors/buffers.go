@@ -0,0 +1,62 @@
+package processors
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// buffer is a read buffer shared by every block cut out of it. It goes back
+// to its pool once the last of them is released.
+type buffer struct {
+	data []byte
+	refs atomic.Int32
+	pool *bufferPool
+}
+
+func (b *buffer) release() {
+	if b.refs.Add(-1) == 0 {
+		b.pool.free <- b
+	}
+}
+
+// bufferPool hands out at most count buffers of size bytes, so reading a
+// stream never takes more than count*size bytes no matter how long it is.
+type bufferPool struct {
+	free      chan *buffer
+	count     int
+	size      int
+	allocated int
+}
+
+func newBufferPool(count, size int) *bufferPool {
+	return &bufferPool{
+		free:  make(chan *buffer, count),
+		count: count,
+		size:  size,
+	}
+}
+
+// get returns a buffer holding a single reference, allocating one if we are
+// still under count or waiting for one to be released otherwise. It is not
+// safe to call get from more than one goroutine.
+func (bp *bufferPool) get(ctx context.Context) (*buffer, error) {
+	var b *buffer
+	select {
+	case b = <-bp.free:
+	default:
+		if bp.allocated < bp.count {
+			bp.allocated++
+			b = &buffer{data: make([]byte, bp.size), pool: bp}
+			break
+		}
+
+		select {
+		case b = <-bp.free:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	b.refs.Store(1)
+	return b, nil
+}
@@ -0,0 +1,304 @@
+package processors
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/itzloop/1brc/constants"
+	"github.com/klauspost/compress/zstd"
+)
+
+type compression int
+
+const (
+	compressionNone compression = iota
+	compressionGzip
+	compressionZstd
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b, 0x08} // ID1, ID2 and CM=deflate
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// detectCompression looks at the magic bytes at the start of the file at p.
+func detectCompression(p string) (compression, error) {
+	input, err := os.Open(p)
+	if err != nil {
+		return compressionNone, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer input.Close()
+
+	magic := make([]byte, 4)
+	n, err := io.ReadFull(input, magic)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return compressionNone, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	switch {
+	case bytes.HasPrefix(magic[:n], gzipMagic):
+		return compressionGzip, nil
+	case bytes.HasPrefix(magic[:n], zstdMagic):
+		return compressionZstd, nil
+	}
+
+	return compressionNone, nil
+}
+
+// maxParallelGzipMember is the largest average compressed member size we
+// decompress in parallel. Members are decompressed into memory whole, so
+// files made of a few huge members are better off streamed.
+const maxParallelGzipMember = 64 * constants.MiB
+
+// openInput opens the file at p for reading, decompressing it on the fly if
+// it starts with gzip or zstd magic bytes. If decoders is more than one zstd
+// decodes blocks concurrently and gzip files made of several members, like
+// bgzip output or gzip files that were cat'ed together, have up to decoders
+// members decompressed in parallel.
+func openInput(p string, decoders int) (io.ReadCloser, error) {
+	c, err := detectCompression(p)
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	switch c {
+	case compressionNone:
+		return input, nil
+	case compressionZstd:
+		zr, err := zstd.NewReader(bufio.NewReaderSize(input, 256*constants.KiB), zstd.WithDecoderConcurrency(max(decoders, 1)))
+		if err != nil {
+			input.Close()
+			return nil, fmt.Errorf("failed to read zstd frame: %w", err)
+		}
+
+		return &zstdFile{Decoder: zr, file: input}, nil
+	}
+
+	fInfo, err := input.Stat()
+	if err != nil {
+		input.Close()
+		return nil, fmt.Errorf("failed to get stat of file: %w", err)
+	}
+
+	if decoders > 1 {
+		starts, err := gzipMembers(input, fInfo.Size())
+		if err != nil {
+			input.Close()
+			return nil, err
+		}
+
+		if len(starts) > 1 && fInfo.Size()/int64(len(starts)) <= maxParallelGzipMember {
+			return newParallelGzipReader(input, starts, fInfo.Size(), decoders), nil
+		}
+	}
+
+	zr, err := gzip.NewReader(bufio.NewReaderSize(input, 256*constants.KiB))
+	if err != nil {
+		input.Close()
+		return nil, fmt.Errorf("failed to read gzip header: %w", err)
+	}
+
+	return &gzipFile{Reader: zr, file: input}, nil
+}
+
+// gzipFile closes both the gzip reader and the file under it.
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (gf *gzipFile) Close() error {
+	return errors.Join(gf.Reader.Close(), gf.file.Close())
+}
+
+// zstdFile closes both the zstd decoder and the file under it.
+type zstdFile struct {
+	*zstd.Decoder
+	file *os.File
+}
+
+func (zf *zstdFile) Close() error {
+	zf.Decoder.Close()
+	return zf.file.Close()
+}
+
+// gzipMembers returns the offsets that look like the start of a gzip member:
+// magic bytes, no reserved flags, a known XFL and a known OS. Compressed data
+// can still look like a header by chance, readers have to cope with that.
+func gzipMembers(r io.ReaderAt, size int64) ([]int64, error) {
+	const (
+		window    = 4 * constants.MiB
+		headerLen = 10
+	)
+
+	var (
+		buf    = make([]byte, window+headerLen-1)
+		starts []int64
+	)
+
+	for off := int64(0); off < size; off += window {
+		n, err := r.ReadAt(buf, off)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to read at %d: %w", off, err)
+		}
+
+		b := buf[:n]
+		for i := 0; i < window && i+headerLen <= n; {
+			j := bytes.Index(b[i:], gzipMagic)
+			if j == -1 {
+				break
+			}
+			i += j
+
+			if i >= window || i+headerLen > n {
+				break
+			}
+
+			flg, xfl, os := b[i+3], b[i+8], b[i+9]
+			if flg&0xe0 == 0 && (xfl == 0 || xfl == 2 || xfl == 4) && (os <= 13 || os == 255) {
+				starts = append(starts, off+int64(i))
+			}
+			i++
+		}
+	}
+
+	return starts, nil
+}
+
+type gzipMember struct {
+	start, end int64
+	data       []byte
+	err        error
+}
+
+// decodeGzipMember decompresses the single gzip member starting at start and
+// reports where it ends.
+func decodeGzipMember(r io.ReaderAt, start, size int64) gzipMember {
+	m := gzipMember{start: start}
+
+	cr := &countingReader{r: io.NewSectionReader(r, start, size-start)}
+	br := bufio.NewReaderSize(cr, 256*constants.KiB)
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		m.err = fmt.Errorf("failed to read gzip header at %d: %w", start, err)
+		return m
+	}
+	zr.Multistream(false)
+
+	m.data, err = io.ReadAll(zr)
+	if err != nil {
+		m.err = fmt.Errorf("failed to decompress gzip member at %d: %w", start, err)
+		return m
+	}
+
+	m.end = start + cr.n - int64(br.Buffered())
+	return m
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// parallelGzipReader decompresses the members of a multi member gzip file in
+// parallel and hands out their content in order. Every candidate start is
+// decoded speculatively, the ones that turn out to be inside another member
+// are thrown away.
+type parallelGzipReader struct {
+	file  *os.File
+	size  int64
+	order chan chan gzipMember
+	stop  chan struct{}
+
+	pos int64 // where the next member has to start
+	cur []byte
+	err error
+}
+
+func newParallelGzipReader(file *os.File, starts []int64, size int64, decoders int) *parallelGzipReader {
+	pg := &parallelGzipReader{
+		file:  file,
+		size:  size,
+		order: make(chan chan gzipMember, decoders),
+		stop:  make(chan struct{}),
+	}
+
+	go func() {
+		defer close(pg.order)
+		for _, start := range starts {
+			fut := make(chan gzipMember, 1)
+			select {
+			case pg.order <- fut:
+			case <-pg.stop:
+				return
+			}
+
+			go func(start int64) {
+				fut <- decodeGzipMember(file, start, size)
+			}(start)
+		}
+	}()
+
+	return pg
+}
+
+func (pg *parallelGzipReader) Read(p []byte) (int, error) {
+	for len(pg.cur) == 0 {
+		if pg.err != nil {
+			return 0, pg.err
+		}
+		pg.cur, pg.err = pg.next()
+	}
+
+	n := copy(p, pg.cur)
+	pg.cur = pg.cur[n:]
+	return n, nil
+}
+
+func (pg *parallelGzipReader) next() ([]byte, error) {
+	for fut := range pg.order {
+		m := <-fut
+		switch {
+		case m.start < pg.pos: // looked like a header but is inside a member
+			continue
+		case m.start > pg.pos:
+			return nil, fmt.Errorf("no gzip member starts at offset %d", pg.pos)
+		case m.err != nil:
+			return nil, m.err
+		}
+
+		pg.pos = m.end
+		return m.data, nil
+	}
+
+	if pg.pos != pg.size {
+		return nil, fmt.Errorf("no gzip member starts at offset %d", pg.pos)
+	}
+
+	return nil, io.EOF
+}
+
+func (pg *parallelGzipReader) Close() error {
+	close(pg.stop)
+	for fut := range pg.order {
+		<-fut // wait for in flight decoders
+	}
+
+	return pg.file.Close()
+}
@@ -0,0 +1,210 @@
+package processors
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gzipMembersOf compresses every part into its own gzip member, the way
+// cat a.gz b.gz or bgzip lay them out.
+func gzipMembersOf(t testing.TB, parts ...[]byte) []byte {
+	t.Helper()
+
+	var out bytes.Buffer
+	for _, part := range parts {
+		zw := gzip.NewWriter(&out)
+		_, err := zw.Write(part)
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+	}
+
+	return out.Bytes()
+}
+
+// splitEvery cuts data every n bytes, ignoring line boundaries.
+func splitEvery(data []byte, n int) [][]byte {
+	var parts [][]byte
+	for len(data) > n {
+		parts = append(parts, data[:n])
+		data = data[n:]
+	}
+
+	return append(parts, data)
+}
+
+func zstdOf(t testing.TB, data []byte) []byte {
+	t.Helper()
+
+	zw, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	defer zw.Close()
+
+	return zw.EncodeAll(data, nil)
+}
+
+func TestCompressedInput(t *testing.T) {
+	p := writeMeasurements(t, 20_000)
+	raw, err := os.ReadFile(p)
+	require.NoError(t, err)
+
+	expected, err := NewSplitBufProcessor(SplitBufOpts{}).Process(p)
+	require.NoError(t, err)
+
+	table := []struct {
+		name string
+		data []byte
+	}{
+		{name: "gzip", data: gzipMembersOf(t, raw)},
+		{name: "gzip members", data: gzipMembersOf(t, splitEvery(raw, 10_007)...)},
+		{name: "gzip empty members", data: gzipMembersOf(t, nil, raw[:100], nil, raw[100:], nil)},
+		{name: "zstd", data: zstdOf(t, raw)},
+	}
+
+	for _, tc := range table {
+		compressed := writeFile(t, tc.data)
+		for _, name := range testProcessors {
+			t.Run(tc.name+"/"+name, func(t *testing.T) {
+				processor, err := New(name, smallConfig())
+				require.NoError(t, err)
+
+				result, err := processor.Process(compressed)
+				require.NoError(t, err)
+				assert.Equal(t, expected.String(), result.String())
+				assert.Equal(t, expected.Rows, result.Rows)
+				assert.Equal(t, int64(len(raw)), result.Bytes)
+			})
+		}
+	}
+}
+
+func TestCompressedParseError(t *testing.T) {
+	p := writeFile(t, gzipMembersOf(t, []byte("Hamburg;12.0\nOslo;1.0\n"), []byte("Oslo;100.0\n")))
+
+	for _, name := range testProcessors {
+		t.Run(name, func(t *testing.T) {
+			processor, err := New(name, smallConfig())
+			require.NoError(t, err)
+
+			_, err = processor.Process(p)
+
+			var perr *ParseError
+			require.ErrorAs(t, err, &perr)
+			assert.Equal(t, p, perr.Path)
+			assert.Equal(t, 3, perr.Line)
+			assert.Equal(t, int64(22), perr.Offset)
+		})
+	}
+}
+
+func TestParallelGzipReader(t *testing.T) {
+	raw := bytes.Repeat([]byte("Hamburg;12.0\nIstanbul;-3.4\n"), 10_000)
+
+	t.Run("members", func(t *testing.T) {
+		p := writeFile(t, gzipMembersOf(t, splitEvery(raw, 4096)...))
+
+		r, err := openInput(p, 4)
+		require.NoError(t, err)
+		require.IsType(t, &parallelGzipReader{}, r)
+
+		got, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		assert.Equal(t, raw, got)
+	})
+
+	t.Run("single member is streamed", func(t *testing.T) {
+		p := writeFile(t, gzipMembersOf(t, raw))
+
+		r, err := openInput(p, 4)
+		require.NoError(t, err)
+		defer r.Close()
+		assert.IsType(t, &gzipFile{}, r)
+	})
+
+	t.Run("header inside a member", func(t *testing.T) {
+		// an uncompressed copy of a gzip header inside the first member
+		// shows up as a candidate that has to be skipped.
+		fake := gzipMembersOf(t, []byte("x"))[:10]
+		first := append(append([]byte("a;1.0\n"), fake...), '\n')
+
+		var out bytes.Buffer
+		zw, err := gzip.NewWriterLevel(&out, gzip.NoCompression)
+		require.NoError(t, err)
+		_, err = zw.Write(first)
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		data := append(out.Bytes(), gzipMembersOf(t, raw)...)
+
+		starts, err := gzipMembers(bytes.NewReader(data), int64(len(data)))
+		require.NoError(t, err)
+		require.Len(t, starts, 3)
+
+		p := writeFile(t, data)
+		r, err := openInput(p, 4)
+		require.NoError(t, err)
+
+		got, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		assert.Equal(t, append(first, raw...), got)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		data := gzipMembersOf(t, splitEvery(raw, 4096)...)
+		p := writeFile(t, data[:len(data)-5])
+
+		r, err := openInput(p, 4)
+		require.NoError(t, err)
+
+		_, err = io.ReadAll(r)
+		assert.Error(t, err)
+		require.NoError(t, r.Close())
+	})
+
+	t.Run("close early", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+		p := writeFile(t, gzipMembersOf(t, splitEvery(raw, 1024)...))
+
+		r, err := openInput(p, 4)
+		require.NoError(t, err)
+
+		_, err = r.Read(make([]byte, 10))
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		assertNoLeak(t, before)
+	})
+}
+
+func TestProcessCompressedCancel(t *testing.T) {
+	raw, err := os.ReadFile(writeMeasurements(t, 20_000))
+	require.NoError(t, err)
+	p := writeFile(t, gzipMembersOf(t, splitEvery(raw, 1024)...))
+
+	for _, name := range testProcessors {
+		t.Run(name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+
+			processor, err := New(name, smallConfig())
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(time.Millisecond, cancel)
+
+			_, err = processor.ProcessContext(ctx, p)
+			if err != nil {
+				assert.ErrorIs(t, err, context.Canceled)
+			}
+			assertNoLeak(t, before)
+		})
+	}
+}
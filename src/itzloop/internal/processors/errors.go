@@ -0,0 +1,138 @@
+package processors
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ParseError is returned by Process when a line of the input could not be
+// parsed. Offset is where the line starts in the file, after decompression
+// for compressed files, and Line is its 1 based
+// line number, or 0 when the input is a stream we can't go back and count
+// lines in.
+type ParseError struct {
+	Path   string
+	Offset int64
+	Line   int
+	Raw    string
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s:%d: failed to parse line %q at offset %d: %v", e.Path, e.Line, e.Raw, e.Offset, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// LineTooLongError is returned when no newline shows up within MaxLineLength
+// bytes after Offset while looking for the end of a line.
+type LineTooLongError struct {
+	Path          string
+	Offset        int64
+	MaxLineLength int
+}
+
+func (e *LineTooLongError) Error() string {
+	return fmt.Sprintf("%s: found no newline in the %d bytes after offset %d, lines must be at most %d bytes long", e.Path, e.MaxLineLength, e.Offset, e.MaxLineLength)
+}
+
+// block is a piece of the input handed to a worker. path and offset are the
+// file buf comes from and where it starts in it, so errors can point at the
+// right place. path is empty when there is only one input. owner is set
+// when buf comes from a bufferPool, workers release the block once they are
+// done with it.
+type block struct {
+	buf    []byte
+	offset int64
+	path   string
+	owner  *buffer
+}
+
+func (b block) release() {
+	if b.owner != nil {
+		b.owner.release()
+	}
+}
+
+// splitBlock is splitbuf for blocks, keeping track of the offsets. Blocks
+// smaller than count are not worth splitting.
+func splitBlock(b block, count int) []block {
+	if len(b.buf) < count {
+		if len(b.buf) == 0 {
+			return nil
+		}
+		return []block{b}
+	}
+
+	bufs := splitbuf(b.buf, count)
+	blocks := make([]block, 0, len(bufs))
+	offset := b.offset
+	for _, buf := range bufs {
+		blocks = append(blocks, block{buf: buf, offset: offset, path: b.path, owner: b.owner})
+		offset += int64(len(buf))
+	}
+
+	return blocks
+}
+
+// newParseError builds a ParseError for the line b.buf[bol:eol]. Workers
+// only see their own block so Line, and Path for single input runs, are left
+// for fillLine.
+func newParseError(b block, bol, eol int, err error) *ParseError {
+	return &ParseError{
+		Path:   b.path,
+		Offset: b.offset + int64(bol),
+		Raw:    string(b.buf[bol:eol]),
+		Err:    err,
+	}
+}
+
+// fillLine sets Line of a ParseError by counting the newlines before its
+// offset, and Path to p unless the worker already knew it. This only happens
+// on the error path so re-reading the start of the file is fine.
+func fillLine(err error, p string) error {
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		return err
+	}
+
+	if perr.Path == "" {
+		perr.Path = p
+	}
+
+	input, openErr := openInput(perr.Path, 1)
+	if openErr != nil {
+		return err
+	}
+	defer input.Close()
+
+	lines, countErr := countLines(io.LimitReader(input, perr.Offset))
+	if countErr != nil {
+		return err
+	}
+	perr.Line = lines + 1
+
+	return err
+}
+
+func countLines(r io.Reader) (int, error) {
+	var (
+		buf   = make([]byte, 64*1024)
+		lines = 0
+	)
+
+	for {
+		n, err := r.Read(buf)
+		lines += bytes.Count(buf[:n], []byte{'\n'})
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return lines, nil
+			}
+			return lines, err
+		}
+	}
+}
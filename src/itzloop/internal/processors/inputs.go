@@ -0,0 +1,73 @@
+package processors
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ExpandPaths turns input arguments into the list of files to process. Globs
+// are expanded, a directory stands for the regular files directly inside it,
+// skipping hidden ones, and anything else is kept as is. Every file shows up
+// once, in the order it was first matched.
+func ExpandPaths(args ...string) ([]string, error) {
+	var (
+		paths []string
+		seen  = map[string]bool{}
+	)
+
+	add := func(p string) {
+		if !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+
+	for _, arg := range args {
+		if strings.ContainsAny(arg, "*?[") {
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("bad pattern %q: %w", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %q", arg)
+			}
+
+			for _, m := range matches {
+				add(m)
+			}
+			continue
+		}
+
+		fInfo, err := os.Stat(arg)
+		if err != nil || !fInfo.IsDir() {
+			add(arg) // missing files fail once they are processed
+			continue
+		}
+
+		entries, err := os.ReadDir(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read directory: %w", err)
+		}
+
+		var files []string
+		for _, e := range entries {
+			if e.Type().IsRegular() && !strings.HasPrefix(e.Name(), ".") {
+				files = append(files, filepath.Join(arg, e.Name()))
+			}
+		}
+		sort.Strings(files)
+
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no files in directory %q", arg)
+		}
+
+		for _, f := range files {
+			add(f)
+		}
+	}
+
+	return paths, nil
+}
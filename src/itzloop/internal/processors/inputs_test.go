@@ -0,0 +1,82 @@
+package processors
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"measurements-2026-10-02.txt", "measurements-2026-10-01.txt", "notes.md", ".hidden"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("a;1.0\n"), 0o644))
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0o755))
+
+	in := func(names ...string) []string {
+		var paths []string
+		for _, name := range names {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+		return paths
+	}
+
+	table := []struct {
+		name     string
+		args     []string
+		expected []string
+		err      bool
+	}{
+		{
+			name:     "file",
+			args:     in("notes.md"),
+			expected: in("notes.md"),
+		},
+		{
+			name:     "glob",
+			args:     in("measurements-2026-10-*.txt"),
+			expected: in("measurements-2026-10-01.txt", "measurements-2026-10-02.txt"),
+		},
+		{
+			name:     "directory",
+			args:     []string{dir},
+			expected: in("measurements-2026-10-01.txt", "measurements-2026-10-02.txt", "notes.md"),
+		},
+		{
+			name:     "duplicates",
+			args:     append(in("notes.md"), dir),
+			expected: in("notes.md", "measurements-2026-10-01.txt", "measurements-2026-10-02.txt"),
+		},
+		{
+			name:     "missing file is kept",
+			args:     in("missing.txt"),
+			expected: in("missing.txt"),
+		},
+		{
+			name: "glob without matches",
+			args: in("*.csv"),
+			err:  true,
+		},
+		{
+			name: "empty directory",
+			args: in("sub"),
+			err:  true,
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			paths, err := ExpandPaths(tc.args...)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, paths)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package processors
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// mmapFile maps the file at p read only and tells the kernel we are going to
+// read all of it front to back. The returned func unmaps it, data must not be
+// used after that.
+func mmapFile(p string) (data []byte, unmap func() error, err error) {
+	input, err := os.Open(p)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer input.Close() // the mapping outlives the file descriptor
+
+	fInfo, err := input.Stat()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get stat of file: %w", err)
+	}
+
+	if fInfo.Size() == 0 { // mmap fails on empty files
+		return []byte{}, func() error { return nil }, nil
+	}
+
+	data, err = syscall.Mmap(int(input.Fd()), 0, int(fInfo.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to mmap file: %w", err)
+	}
+
+	// these are only hints, a failure here is not worth giving up over
+	_ = syscall.Madvise(data, syscall.MADV_SEQUENTIAL)
+	_ = syscall.Madvise(data, syscall.MADV_WILLNEED)
+
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
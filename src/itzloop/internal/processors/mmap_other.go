@@ -0,0 +1,9 @@
+//go:build !linux
+
+package processors
+
+import "errors"
+
+func mmapFile(p string) (data []byte, unmap func() error, err error) {
+	return nil, nil, errors.New("mmap input is only supported on linux")
+}
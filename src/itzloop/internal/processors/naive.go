@@ -0,0 +1,234 @@
+package processors
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/itzloop/1brc/types"
+	"github.com/itzloop/1brc/utils"
+)
+
+type NaiveOpts struct {
+	Aggregates types.AgOptions
+	Stations   *types.StationFilter
+	Log        *log.Logger
+	Progress   *Progress
+}
+
+func init() {
+	Register("naive", func(cfg Config) Processor {
+		return NewNaiveProcessor(NaiveOpts{
+			Aggregates: cfg.Aggregates,
+			Stations:   cfg.Stations,
+			Log:        cfg.Log,
+			Progress:   cfg.Progress,
+		})
+	})
+}
+
+// NaiveProcessor is the simplest implementation we could come up with, one
+// goroutine, one line at a time and the standard library for everything. It
+// is slow on purpose, it is what the other processors are checked against, so
+// it doesn't share their parsing, aggregation or rounding either.
+type NaiveProcessor struct {
+	opts NaiveOpts
+}
+
+// naiveMeasurement is the grammar of a measurement, utils.ParseTenths
+// accepts exactly the same strings.
+var naiveMeasurement = regexp.MustCompile(`^-?[0-9]{1,2}\.[0-9]$`)
+
+// naiveStation is what naive keeps per station, in tenths.
+type naiveStation struct {
+	min, max, sum, sumSq int64
+	count                int
+}
+
+func NewNaiveProcessor(opts NaiveOpts) *NaiveProcessor {
+	if opts.Log == nil {
+		opts.Log = log.New(io.Discard, "", 0)
+	}
+
+	return &NaiveProcessor{
+		opts: opts,
+	}
+}
+
+func (np *NaiveProcessor) Process(p string) (result *types.Result, err error) {
+	return np.ProcessContext(context.Background(), p)
+}
+
+func (np *NaiveProcessor) ProcessContext(ctx context.Context, p string) (result *types.Result, err error) {
+	input, err := openInput(p, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	defer func() {
+		if err := input.Close(); err != nil {
+			np.opts.Log.Printf("error when trying to close the file: %v\n", err)
+		}
+	}()
+
+	result, err = np.ProcessReader(ctx, input)
+	if err != nil {
+		return nil, fillLine(err, p)
+	}
+
+	return result, nil
+}
+
+func (np *NaiveProcessor) ProcessReader(ctx context.Context, r io.Reader) (result *types.Result, err error) {
+	var (
+		stations = map[string]*naiveStation{}
+		dists    map[string]*types.Distribution
+		cr       = &countingReader{r: r}
+		scanner  = bufio.NewScanner(cr)
+		offset   int64
+		line     int
+		rows     int64
+
+		rp            = np.opts.Progress.startReaders(1)[0]
+		reportedBytes int64 // already added to Progress
+		reportedRows  int64
+	)
+	defer rp.set(ReaderDone)
+	rp.set(ReaderReading)
+
+	scanner.Split(scanLines)
+	if np.opts.Aggregates.Any() {
+		dists = map[string]*types.Distribution{}
+	}
+
+	// progress is updated every 4096 lines, same as ctx is checked
+	report := func() {
+		np.opts.Progress.addBytes(cr.n - reportedBytes)
+		np.opts.Progress.addRows(rows - reportedRows)
+		rp.bytes.Store(cr.n)
+		reportedBytes, reportedRows = cr.n, rows
+	}
+
+	for scanner.Scan() {
+		line++
+		if line%4096 == 0 {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			report()
+		}
+
+		text := scanner.Text()
+		station, measure, ok := strings.Cut(text, ";")
+		if ok && !np.opts.Stations.Match([]byte(station)) {
+			offset += int64(len(scanner.Bytes())) + 1
+			continue
+		}
+
+		if !ok || !naiveMeasurement.MatchString(measure) {
+			return nil, &ParseError{Offset: offset, Line: line, Raw: text, Err: utils.ErrInvalidMeasurement}
+		}
+		v, err := strconv.ParseFloat(measure, 64)
+		if err != nil {
+			return nil, &ParseError{Offset: offset, Line: line, Raw: text, Err: err}
+		}
+		offset += int64(len(scanner.Bytes())) + 1
+
+		tenths := int64(math.Round(v * 10))
+		s, ok := stations[station]
+		if !ok {
+			s = &naiveStation{min: tenths, max: tenths}
+			stations[station] = s
+			if dists != nil {
+				dists[station] = types.NewDistribution(np.opts.Aggregates)
+			}
+		}
+		s.min = min(s.min, tenths)
+		s.max = max(s.max, tenths)
+		s.sum += tenths
+		s.sumSq += tenths * tenths
+		s.count++
+		if dists != nil {
+			dists[station].Add(int16(tenths))
+		}
+		rows++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the input: %w", err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	report()
+
+	measures := make(types.AgMeasureMap, len(stations))
+	for k, s := range stations {
+		measures[k] = &types.AgMeasures{
+			Min:   int16(s.min),
+			Max:   int16(s.max),
+			Sum:   s.sum,
+			SumSq: s.sumSq,
+			Count: s.count,
+		}
+	}
+
+	result = types.NewResult(measures, cr.n)
+	result.Distributions = dists
+	return result, nil
+}
+
+// scanLines is bufio.ScanLines without dropping a '\r' before the newline,
+// which the other processors don't accept either.
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
+
+// NaiveString formats r in the 1BRC output format like Result.String, but
+// with floats and strconv instead of the integer rounding of the types
+// package. Comparing it to the String of another result checks the output
+// and not just the aggregates.
+func NaiveString(r *types.Result) string {
+	stations := make([]string, 0, len(r.Measures))
+	for k := range r.Measures {
+		stations = append(stations, k)
+	}
+	sort.Strings(stations)
+
+	format := func(tenths float64) string {
+		return strconv.FormatFloat(tenths/10, 'f', 1, 64)
+	}
+
+	var str strings.Builder
+	str.WriteString("{")
+	for i, k := range stations {
+		if i > 0 {
+			str.WriteString(", ")
+		}
+
+		m := r.Measures[k]
+		// halves round up, toward positive infinity
+		mean := math.Floor(float64(m.Sum)/float64(m.Count) + 0.5)
+		fmt.Fprintf(&str, "%s=%s/%s/%s", k, format(float64(m.Min)), format(mean), format(float64(m.Max)))
+	}
+	str.WriteString("}")
+
+	return str.String()
+}
@@ -2,6 +2,7 @@ package processors
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -10,7 +11,6 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
-	"unsafe"
 
 	"github.com/itzloop/1brc/constants"
 	"github.com/itzloop/1brc/types"
@@ -21,10 +21,52 @@ type ParallelReadOpts struct {
 	Processors         int
 	ProcessorChanSize  int
 	AggregatorChanSize int
-	Log                *log.Logger
+	ChunkCount         int
+	ChunksChanSize     int
+	ReaderCount        int
+	SplitCount         int
+
+	// LookAheadBytes is how much is read past a split point at first to find
+	// the end of the line it falls in. Windows double from there on until
+	// the line is found to be longer than MaxLineLength.
+	LookAheadBytes int
+	MaxLineLength  int
+
+	// Mmap maps the input into memory instead of reading it, chunks are then
+	// sub-slices of the mapping and nothing is copied.
+	Mmap bool
+
+	Aggregates types.AgOptions
+	Stations   *types.StationFilter
+	Log        *log.Logger
+	Progress   *Progress
 }
+
+func init() {
+	Register("parallel-read", func(cfg Config) Processor {
+		return NewParallelReadProcessor(ParallelReadOpts{
+			Processors:         cfg.Processors,
+			ProcessorChanSize:  cfg.ProcessorChanSize,
+			AggregatorChanSize: cfg.AggregatorChanSize,
+			ChunkCount:         cfg.ChunkCount,
+			ChunksChanSize:     cfg.ChunksChanSize,
+			ReaderCount:        cfg.ReaderCount,
+			LookAheadBytes:     cfg.LookAheadBytes,
+			MaxLineLength:      cfg.MaxLineLength,
+			SplitCount:         cfg.SplitCount,
+			Mmap:               cfg.Mmap,
+			Aggregates:         cfg.Aggregates,
+			Stations:           cfg.Stations,
+			Log:                cfg.Log,
+			Progress:           cfg.Progress,
+		})
+	})
+}
+
 type ParallelReadProcessor struct {
-	globalAg     map[string]*types.AgMeasures
+	globalAg     *aggregate
+	fileAgs      map[string]*aggregate
+	stats        *types.Stats
 	aggregatorWG sync.WaitGroup
 	processorWG  sync.WaitGroup
 	opts         ParallelReadOpts
@@ -35,72 +77,222 @@ func NewParallelReadProcessor(opts ParallelReadOpts) *ParallelReadProcessor {
 		opts.Log = log.New(io.Discard, "", 0)
 	}
 
+	def := DefaultConfig()
+	if opts.Processors <= 0 {
+		opts.Processors = def.Processors
+	}
+	if opts.ChunkCount <= 0 {
+		opts.ChunkCount = def.ChunkCount
+	}
+	if opts.ChunksChanSize < 0 {
+		opts.ChunksChanSize = def.ChunksChanSize
+	}
+	if opts.ReaderCount <= 0 {
+		opts.ReaderCount = def.ReaderCount
+	}
+	if opts.LookAheadBytes <= 0 {
+		opts.LookAheadBytes = def.LookAheadBytes
+	}
+	if opts.MaxLineLength <= 0 {
+		opts.MaxLineLength = def.MaxLineLength
+	}
+	if opts.SplitCount <= 0 {
+		opts.SplitCount = def.SplitCount
+	}
+
 	return &ParallelReadProcessor{
-		globalAg:     map[string]*types.AgMeasures{},
+		globalAg:     newAggregate(opts.Aggregates),
 		aggregatorWG: sync.WaitGroup{},
 		processorWG:  sync.WaitGroup{},
 		opts:         opts,
 	}
 }
 
-func (prp *ParallelReadProcessor) Process(p string) (result string, err error) {
+func (prp *ParallelReadProcessor) Process(p string) (result *types.Result, err error) {
+	return prp.ProcessContext(context.Background(), p)
+}
+
+func (prp *ParallelReadProcessor) ProcessContext(ctx context.Context, p string) (result *types.Result, err error) {
+	return prp.ProcessFiles(ctx, []string{p}, false)
+}
+
+// ProcessFiles processes every file in paths in one run. Plain files are
+// split into chunks that all go through the same readers and workers,
+// compressed files are decompressed one after the other.
+func (prp *ParallelReadProcessor) ProcessFiles(ctx context.Context, paths []string, perFile bool) (result *types.Result, err error) {
+	start := time.Now()
+
+	var plain, compressed []string
+	for _, p := range paths {
+		c, err := detectCompression(p)
+		if err != nil {
+			return nil, err
+		}
+
+		if c == compressionNone {
+			plain = append(plain, p)
+			continue
+		}
+		compressed = append(compressed, p)
+	}
+
+	result = types.NewResult(types.AgMeasureMap{}, 0)
+	if perFile {
+		result.Files = map[string]*types.Result{}
+	}
+
+	if len(plain) > 0 {
+		result, err = prp.processPlain(ctx, plain, perFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range compressed {
+		r, err := prp.processCompressed(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Merge(r)
+		if perFile {
+			result.Files[p] = r
+		}
+	}
+
+	if result.Stats != nil {
+		result.Stats.Wall = time.Since(start)
+	}
+
+	return result, nil
+}
+
+func (prp *ParallelReadProcessor) processPlain(ctx context.Context, paths []string, perFile bool) (result *types.Result, err error) {
+	// the first reader or worker to fail cancels ctx with its error and
+	// everyone else winds down.
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	prp.globalAg = newAggregate(prp.opts.Aggregates)
+	prp.fileAgs = map[string]*aggregate{}
+	prp.stats = types.NewStats(prp.opts.ReaderCount, prp.opts.Processors)
+
 	// create processrors
-	agCh := make(chan map[string]*types.AgMeasures, prp.opts.AggregatorChanSize)
+	agCh := make(chan fileAg, prp.opts.AggregatorChanSize)
 	prp.aggregatorWG.Add(1)
-	go prp.aggregator(agCh)
+	go prp.aggregator(agCh, perFile)
 
 	prp.processorWG.Add(prp.opts.Processors)
-	processorChan := make(chan []byte, prp.opts.ProcessorChanSize)
+	processorChan := make(chan block, prp.opts.ProcessorChanSize)
 	for i := 0; i < prp.opts.Processors; i++ {
 		i := i
-		go prp.process(i, processorChan, agCh)
+		go prp.process(ctx, cancel, i, processorChan, agCh, perFile)
+	}
+
+	// workers drain whatever is left in processorChan and the aggregator
+	// drains agCh, so closing both in order is enough to stop everything.
+	shutdown := func() {
+		close(processorChan)
+		prp.processorWG.Wait()
+
+		close(agCh)
+		prp.aggregatorWG.Wait()
 	}
 
 	var (
-		chunkCount     = 8
-		lookAheadBytes = 106
-		chunksChanSize = 8
-		readerCount    = 8
+		chunkCount     = prp.opts.ChunkCount
+		lookAheadBytes = prp.opts.LookAheadBytes
+		maxLineLength  = prp.opts.MaxLineLength
+		chunksChanSize = prp.opts.ChunksChanSize
+		readerCount    = prp.opts.ReaderCount
 		readerWG       = sync.WaitGroup{}
 		overallBytes   atomic.Int64
 	)
 
 	start := time.Now()
-	chunks, err := splitFile(p, chunkCount, lookAheadBytes)
+	chunks, err := splitFiles(paths, chunkCount, lookAheadBytes, maxLineLength)
 	if err != nil {
-		return "", err
+		shutdown()
+		return nil, err
+	}
+	prp.stats.Split = time.Since(start)
+	prp.opts.Log.Printf("it took %s to split %d files into %d chunks: %v\n", prp.stats.Split, len(paths), len(chunks), chunks)
+
+	var mapped map[string][]byte
+	if prp.opts.Mmap {
+		mapped = map[string][]byte{}
+		for _, p := range paths {
+			data, unmap, err := mmapFile(p)
+			if err != nil {
+				shutdown()
+				return nil, err
+			}
+			mapped[p] = data
+
+			// runs after shutdown so no worker is looking at data anymore
+			defer func() {
+				if err := unmap(); err != nil {
+					prp.opts.Log.Printf("error when trying to unmap the file: %v\n", err)
+				}
+			}()
+		}
 	}
-	prp.opts.Log.Printf("it took %s to split file into %d chunks\n", time.Since(start), len(chunks))
-	fmt.Println(chunks)
 
 	start = time.Now()
 	chunksChan := make(chan chunk, chunksChanSize)
+	progress := prp.opts.Progress.startReaders(readerCount)
 	readerWG.Add(readerCount)
 	for i := 0; i < readerCount; i++ {
 		i := i
 		go func(id int, ch <-chan chunk) {
 			defer readerWG.Done()
 
-			input, err := os.Open(p)
-			if err != nil {
-				prp.opts.Log.Panicf("reader %d: failed to open file: %v\n", id, err)
+			rs := &prp.stats.Readers[id]
+			rp := progress[id]
+			defer rp.set(ReaderDone)
+
+			if mapped != nil {
+				prp.readMapped(ctx, mapped, ch, processorChan, &overallBytes, rs, rp)
+				return
 			}
 
+			// files are opened the first time this reader gets one of their
+			// chunks and stay open until the end of the run.
+			inputs := map[string]*os.File{}
 			defer func() {
-				if err = input.Close(); err != nil {
-					prp.opts.Log.Printf("reader %d: error when trying to close the file: %v\n", id, err)
+				for _, input := range inputs {
+					if err := input.Close(); err != nil {
+						prp.opts.Log.Printf("reader %d: error when trying to close the file: %v\n", id, err)
+					}
 				}
 			}()
 
 			for chunk := range ch {
+				if ctx.Err() != nil {
+					continue // drain chunksChan
+				}
+
+				input, ok := inputs[chunk.path]
+				if !ok {
+					var err error
+					input, err = os.Open(chunk.path)
+					if err != nil {
+						cancel(fmt.Errorf("reader %d: failed to open file: %w", id, err))
+						continue
+					}
+					inputs[chunk.path] = input
+				}
+
 				_, err := input.Seek(chunk.offset, io.SeekStart)
 				if err != nil {
-					prp.opts.Log.Panicf("reader %d: failed to seek to %d: %v", id, chunk.offset, err)
+					cancel(fmt.Errorf("reader %d: failed to seek to %d: %w", id, chunk.offset, err))
+					continue
 				}
 
 				remainingBytes := chunk.len
+				pos := chunk.offset // where the next read starts in the file
 				var remainder []byte
-				for remainingBytes > 0 {
+				for remainingBytes > 0 && ctx.Err() == nil {
 					bufSize := chunk.len
 					if chunk.len > constants.GiB { // limitation of go read call
 						bufSize = constants.GiB
@@ -108,27 +300,40 @@ func (prp *ParallelReadProcessor) Process(p string) (result string, err error) {
 					}
 					buf := make([]byte, bufSize+int64(len(remainder)))
 					start := time.Now()
+					rp.set(ReaderReading)
 					n, err := input.Read(buf[len(remainder):])
-					prp.opts.Log.Printf("reader %d: it took %s to read %d bytes at offset %d\n", id, time.Since(start), bufSize, chunk.offset)
+					end := time.Since(start)
+					prp.opts.Log.Printf("reader %d: it took %s to read %d bytes at offset %d\n", id, end, bufSize, chunk.offset)
+					rs.Read += end
+					rs.Reads++
+					rs.Bytes += int64(n)
 					remainingBytes -= int64(n)
 					overallBytes.Add(int64(n))
+					rp.bytes.Add(int64(n))
+					prp.opts.Progress.addBytes(int64(n))
 					if err != nil {
 						if err == io.EOF {
 							prp.opts.Log.Printf("reader %d: EOF\n", id)
 							break
 						}
-						prp.opts.Log.Panicf("failed to seek to %d: %v", chunk.offset, err)
+						cancel(fmt.Errorf("reader %d: failed to read %s at %d: %w", id, chunk.path, pos, err))
+						break
 					}
 
 					// prepend remainder
 					copy(buf, remainder)
+					b := block{buf: buf, offset: pos - int64(len(remainder)), path: chunk.path}
+					pos += int64(n)
 
+					// chunks end on a newline, or at the end of the file for
+					// the last one, so only reads in the middle of a chunk
+					// leave a remainder.
 				findRemainder:
-					for i := len(buf) - 1; i >= 0; i-- {
+					for i := len(buf) - 1; i >= 0 && remainingBytes > 0; i-- {
 						switch buf[i] {
 						case '\n':
 							remainder = buf[i+1:]
-							buf = buf[:i+1]
+							b.buf = buf[:i+1]
 							prp.opts.Log.Printf("reader %d: found %d bytes remainder buf[%d:%d]=%s\n", id, len(remainder), i+1, len(buf), string(remainder))
 							break findRemainder
 						}
@@ -137,119 +342,336 @@ func (prp *ParallelReadProcessor) Process(p string) (result string, err error) {
 					// processorChan <- buf
 					// TODO what about not split buffering??
 					start = time.Now()
-					bufs := splitbuf(buf, 4)
-					for _, buf := range bufs {
-						processorChan <- buf
+					rp.set(ReaderSending)
+					blocks := splitBlock(b, prp.opts.SplitCount)
+				send:
+					for _, b := range blocks {
+						select {
+						case processorChan <- b:
+						case <-ctx.Done():
+							break send
+						}
 					}
-					prp.opts.Log.Printf("reader %d: it took %s to split buf and send to processors\n", id, time.Since(start))
+					end = time.Since(start)
+					rs.Send += end
+					rp.set(ReaderIdle)
+					prp.opts.Log.Printf("reader %d: it took %s to split buf and send to processors\n", id, end)
 				}
 			}
 		}(i, chunksChan)
 	}
 
+feed:
 	for _, chunk := range chunks {
-		chunksChan <- chunk
+		select {
+		case chunksChan <- chunk:
+		case <-ctx.Done():
+			break feed
+		}
 	}
 	close(chunksChan)
 	readerWG.Wait()
 	prp.opts.Log.Printf("it took %s to fully read %d bytes\n", time.Since(start), overallBytes.Load())
 
-	close(processorChan)
-	prp.processorWG.Wait()
+	shutdown()
 
-	close(agCh)
-	prp.aggregatorWG.Wait()
+	if err := context.Cause(ctx); err != nil {
+		return nil, fillLine(err, paths[0])
+	}
 
 	prp.opts.Log.Printf("it took %s to fully process and aggregate %d bytes\n", time.Since(start), overallBytes.Load())
-	return types.AgMeasureMap(prp.globalAg).SortedString(), nil
+	prp.stats.Sum()
+	result = prp.globalAg.result(overallBytes.Load())
+	result.Stats = prp.stats
+	if perFile {
+		sizes := map[string]int64{}
+		for _, c := range chunks {
+			sizes[c.path] += c.len
+		}
+
+		result.Files = map[string]*types.Result{}
+		for _, p := range paths {
+			ag, ok := prp.fileAgs[p]
+			if !ok { // empty file
+				ag = newAggregate(prp.opts.Aggregates)
+			}
+			result.Files[p] = ag.result(sizes[p])
+		}
+	}
+
+	return result, nil
+}
+
+// StreamChunkSize is the ChunkSize for streams, compressed files and stdin.
+// Workers only start once a whole chunk has been read, so it has to be a lot
+// smaller than the default that suits reading files.
+const StreamChunkSize = 32 * constants.MiB
+
+// processCompressed handles compressed files, which can't be split at byte
+// offsets. Instead ReaderCount decoders decompress the file, in parallel for
+// multi member gzip and zstd, and the stream is fed to split-buf's workers.
+func (prp *ParallelReadProcessor) processCompressed(ctx context.Context, p string) (*types.Result, error) {
+	input, err := openInput(p, prp.opts.ReaderCount)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := input.Close(); err != nil {
+			prp.opts.Log.Printf("error when trying to close the file: %v\n", err)
+		}
+	}()
+
+	sbp := NewSplitBufProcessor(SplitBufOpts{
+		Processors:         prp.opts.Processors,
+		ProcessorChanSize:  prp.opts.ProcessorChanSize,
+		AggregatorChanSize: prp.opts.AggregatorChanSize,
+		ChunkSize:          StreamChunkSize,
+		ReadBuffers:        prp.opts.ChunksChanSize + 2,
+		Aggregates:         prp.opts.Aggregates,
+		Stations:           prp.opts.Stations,
+		Log:                prp.opts.Log,
+		Progress:           prp.opts.Progress,
+	})
+
+	result, err := sbp.ProcessReader(ctx, input)
+	if err != nil {
+		return nil, fillLine(err, p)
+	}
+
+	return result, nil
+}
+
+// readMapped hands every chunk of the mapped files to the workers, no reads
+// and no remainders since chunks already end on a newline.
+func (prp *ParallelReadProcessor) readMapped(ctx context.Context, mapped map[string][]byte, ch <-chan chunk, processorChan chan<- block, overallBytes *atomic.Int64, rs *types.ReaderStats, rp *readerProgress) {
+	for chunk := range ch {
+		if ctx.Err() != nil {
+			continue // drain chunksChan
+		}
+
+		data := mapped[chunk.path]
+		b := block{buf: data[chunk.offset : chunk.offset+chunk.len], offset: chunk.offset, path: chunk.path}
+		overallBytes.Add(chunk.len)
+		rs.Bytes += chunk.len
+		rp.bytes.Add(chunk.len)
+		prp.opts.Progress.addBytes(chunk.len)
+
+		start := time.Now()
+		rp.set(ReaderSending)
+		for _, b := range splitBlock(b, prp.opts.SplitCount) {
+			select {
+			case processorChan <- b:
+			case <-ctx.Done():
+			}
+		}
+		rs.Send += time.Since(start)
+		rp.set(ReaderIdle)
+	}
+}
+
+// fileAg is what a worker aggregated for one file, or for every file when
+// path is empty.
+type fileAg struct {
+	path string
+	ag   *utils.CustomMap
 }
 
-func (prp *ParallelReadProcessor) aggregator(agCh <-chan map[string]*types.AgMeasures) {
+func (prp *ParallelReadProcessor) aggregator(agCh <-chan fileAg, perFile bool) {
 	defer prp.aggregatorWG.Done()
 	prp.opts.Log.Println("aggregator start")
 	d := atomic.Int64{}
 	for localAg := range agCh {
 		start := time.Now()
-		for k, v := range localAg {
-			agM, ok := prp.globalAg[k]
-			if !ok {
-				agM = &types.AgMeasures{
-					Min: 100,
-					Max: -100,
-				}
-				prp.globalAg[k] = agM
+		prp.stats.AggregatorBacklog = max(prp.stats.AggregatorBacklog, len(agCh)+1)
+
+		prp.globalAg.merge(localAg.ag)
+		if perFile {
+			fileAg := prp.fileAgs[localAg.path]
+			if fileAg == nil {
+				fileAg = newAggregate(prp.opts.Aggregates)
+				prp.fileAgs[localAg.path] = fileAg
 			}
-			agM.Max = max(agM.Max, v.Max)
-			agM.Min = min(agM.Min, v.Min)
-			agM.Total += float64(v.Total)
-			agM.Count += v.Count
+			fileAg.merge(localAg.ag)
 		}
 		dd := time.Since(start)
 		d.Add(int64(dd.Nanoseconds()))
-		// prp.opts.Log.Printf("aggregator: it took %s to aggregate %d results\n", dd.String(), len(localAg))
 	}
 
-	prp.opts.Log.Printf("aggregator: it took %s to fully aggregate all results\n", time.Duration(d.Load()))
+	prp.stats.Aggregate = time.Duration(d.Load())
+	prp.opts.Log.Printf("aggregator: it took %s to fully aggregate all results\n", prp.stats.Aggregate)
 }
 
-func (prp *ParallelReadProcessor) process(id int, ch <-chan []byte, resultsCh chan<- map[string]*types.AgMeasures) {
+func (prp *ParallelReadProcessor) process(ctx context.Context, cancel context.CancelCauseFunc, id int, ch <-chan block, resultsCh chan<- fileAg, perFile bool) {
 	defer prp.processorWG.Done()
 
-	for buf := range ch {
-		ag := map[string]*types.AgMeasures{}
+	// every worker aggregates into its own map for the whole run and hands it
+	// to the aggregator once ch is closed. With perFile there is one map per
+	// file the worker has seen.
+	var (
+		ags    = map[string]*utils.CustomMap{}
+		ag     *utils.CustomMap
+		agPath string
+		ws     = &prp.stats.Workers[id]
+	)
+blocks:
+	for {
+		waitStart := time.Now()
+		b, ok := <-ch
+		if !ok {
+			break
+		}
+		ws.Wait += time.Since(waitStart)
+
+		if ctx.Err() != nil {
+			continue // drain ch so readers never block
+		}
+
+		path := ""
+		if perFile {
+			path = b.path
+		}
+		if ag == nil || path != agPath {
+			ag, agPath = ags[path], path
+			if ag == nil {
+				ag = utils.NewCustomMapWith(utils.MaxStations, prp.opts.Aggregates)
+				ag.SetFilter(prp.opts.Stations)
+				ags[path] = ag
+			}
+		}
+
+		buf := b.buf
 		i := 0
-		bol := 0  // begining of line
-		eost := 0 // end of station name
-		// start := time.Now()
+		bol := 0              // begining of line
+		eost := -1            // end of station name, < bol until the line has a ';'
+		h := utils.HashOffset // hash of the line so far
+		var stHash uint64     // hash of the station name
+		start := time.Now()
 		totalMeasurements := 0
 		for i = 0; i < len(buf); i++ {
 			switch buf[i] {
 			case ';': // means we reached the end of station name
-				eost = i
+				if eost < bol { // the first one, like strings.Cut
+					eost = i
+					stHash = h
+				}
 			case '\n':
-				if eost < bol {
-					continue
+				h = utils.HashOffset
+				if eost < bol { // blank line or no ';'
+					cancel(newParseError(b, bol, i, utils.ErrInvalidMeasurement))
+					continue blocks
 				}
 				// buf[bol:eost]: station name
 				// buf[eost + 1:i]: measurement
-				m, err := utils.BtofV2(buf[eost+1 : i])
+				st, dist := ag.Select(buf[bol:eost], stHash)
+				if st == nil { // skipped by the station filter, not even parsed
+					bol = i + 1
+					continue
+				}
+				m, err := utils.ParseTenths(buf[eost+1 : i])
 				if err != nil {
-					prp.opts.Log.Panicf("worker %d: failed to parse %v=buf[%d:%d]=%s, eost=%d to float: %v\n", id, buf[bol:i+1], bol, i+1, string(buf[bol:i]), eost, err)
+					prp.opts.Log.Printf("worker %d: failed to parse %v=buf[%d:%d]=%s, eost=%d to tenths: %v\n", id, buf[bol:i+1], bol, i+1, string(buf[bol:i]), eost, err)
+					cancel(newParseError(b, bol, i, err))
+					continue blocks
 				}
-				stNameSubSlice := buf[bol:eost]
-				stName := unsafe.String(&stNameSubSlice[0], len(stNameSubSlice))
-				agM, ok := ag[stName]
-				if !ok {
-					agM = &types.AgMeasures{
-						Min: 100,
-						Max: -100,
-					}
-					ag[stName] = agM
+				st.Add(m)
+				if dist != nil { // only with opt-in aggregates
+					dist.Add(m)
 				}
-				agM.Max = max(agM.Max, m)
-				agM.Min = min(agM.Min, m)
-				agM.Total += float64(m)
-				agM.Count++
 
 				totalMeasurements++
 				bol = i + 1 // set bol to be start of next line
+			default:
+				h = utils.HashByte(h, buf[i])
+			}
+		}
+
+		// the last line of a file doesn't have to end with a newline
+		if bol < len(buf) {
+			if eost < bol {
+				cancel(newParseError(b, bol, len(buf), utils.ErrInvalidMeasurement))
+				continue blocks
+			}
+			if st, dist := ag.Select(buf[bol:eost], stHash); st != nil {
+				m, err := utils.ParseTenths(buf[eost+1:])
+				if err != nil {
+					cancel(newParseError(b, bol, len(buf), err))
+					continue blocks
+				}
+				st.Add(m)
+				if dist != nil {
+					dist.Add(m)
+				}
+				totalMeasurements++
 			}
 		}
 
-		resultsCh <- ag
+		end := time.Since(start)
+		ws.Parse += end
+		ws.Bytes += int64(len(buf))
+		ws.Rows += int64(totalMeasurements)
+		ws.Blocks++
+		prp.opts.Progress.addRows(int64(totalMeasurements))
+	}
 
-		// end := time.Since(start)
-		// prp.opts.Log.Printf("worker %d: it took %s to proccess %d measurements\n", id, end, totalMeasurements)
+	if ctx.Err() == nil {
+		for path, ag := range ags {
+			resultsCh <- fileAg{path: path, ag: ag}
+		}
 	}
 }
 
 type chunk struct {
 	id     int
+	path   string
 	offset int64
 	len    int64
 }
 
-func splitFile(p string, count, lookAheadBytes int) ([]chunk, error) {
+// splitFiles splits the files in paths into about count chunks in total,
+// each file getting a share of them that matches its size.
+func splitFiles(paths []string, count, lookAheadBytes, maxLineLength int) ([]chunk, error) {
+	var (
+		sizes  = make([]int64, len(paths))
+		total  int64
+		chunks []chunk
+	)
+
+	for i, p := range paths {
+		fInfo, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get stat of file: %w\n", err)
+		}
+
+		sizes[i] = fInfo.Size()
+		total += fInfo.Size()
+	}
+
+	for i, p := range paths {
+		if sizes[i] == 0 {
+			continue
+		}
+
+		n := max(int64(count)*sizes[i]/total, 1)
+		fileChunks, err := splitFile(p, int(min(n, sizes[i])), lookAheadBytes, maxLineLength)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range fileChunks {
+			c.id = len(chunks)
+			chunks = append(chunks, c)
+		}
+	}
+
+	return chunks, nil
+}
+
+// splitFile splits the file at p into about count chunks of about the same
+// size. Every chunk but the last ends right after a newline, the last one
+// ends where the file does. It fails with a *LineTooLongError if a split
+// point falls in a line longer than maxLineLength.
+func splitFile(p string, count, lookAheadBytes, maxLineLength int) ([]chunk, error) {
 
 	// TODO parallel
 	input, err := os.Open(p)
@@ -265,7 +687,7 @@ func splitFile(p string, count, lookAheadBytes int) ([]chunk, error) {
 
 	var (
 		n          = fInfo.Size()
-		chunkBytes = fInfo.Size() / int64(count)
+		chunkBytes = fInfo.Size() / int64(max(count, 1))
 		chunks     []chunk
 		remainder  int64
 		id         = 0
@@ -274,6 +696,7 @@ func splitFile(p string, count, lookAheadBytes int) ([]chunk, error) {
 	for i := int64(0); i < fInfo.Size(); i += chunkBytes + remainder {
 		if i+chunkBytes >= n { // last chunk so just go to the end
 			chunks = append(chunks, chunk{
+				path:   p,
 				offset: i,
 				len:    n - i,
 				id:     id,
@@ -281,19 +704,17 @@ func splitFile(p string, count, lookAheadBytes int) ([]chunk, error) {
 			break
 		}
 
-		buf := make([]byte, lookAheadBytes)
-		_, err := input.ReadAt(buf, i+chunkBytes)
-		if err != nil && err != io.EOF {
+		remainder, err = lineEnd(input, i+chunkBytes, lookAheadBytes, maxLineLength)
+		if err != nil {
+			var lerr *LineTooLongError
+			if errors.As(err, &lerr) {
+				lerr.Path = p
+			}
 			return nil, err
 		}
 
-		remainder = int64(bytes.IndexByte(buf, '\n'))
-		if remainder == -1 {
-			return nil, errors.New("wtf")
-		}
-		remainder += 1 // use as len not index
-
 		chunks = append(chunks, chunk{
+			path:   p,
 			offset: i,
 			len:    chunkBytes + remainder,
 			id:     id,
@@ -303,3 +724,37 @@ func splitFile(p string, count, lookAheadBytes int) ([]chunk, error) {
 
 	return chunks, nil
 }
+
+// lineEnd returns the number of bytes from off up to and including the next
+// newline, or up to the end of r if there is none. It reads window bytes past
+// off first and twice as many as the last time after that, giving up with a
+// *LineTooLongError once more than maxLineLength bytes went by without a
+// newline. The last read stops right where the newline has to be, so the
+// limit holds to the byte whatever the window.
+func lineEnd(r io.ReaderAt, off int64, window, maxLineLength int) (int64, error) {
+	end := off + int64(maxLineLength) + 1 // one past the newline of the longest line
+	buf := make([]byte, max(window, 1))
+	for pos := off; ; {
+		if pos >= end {
+			return 0, &LineTooLongError{Offset: off, MaxLineLength: maxLineLength}
+		}
+
+		n, err := r.ReadAt(buf[:min(int64(len(buf)), end-pos)], pos)
+		if i := bytes.IndexByte(buf[:n], '\n'); i != -1 {
+			return pos + int64(i) + 1 - off, nil
+		}
+
+		if errors.Is(err, io.EOF) {
+			return pos + int64(n) - off, nil
+		}
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to read at %d: %w", pos, err)
+		}
+
+		pos += int64(n)
+		if len(buf) < maxLineLength {
+			buf = make([]byte, min(2*len(buf), maxLineLength))
+		}
+	}
+}
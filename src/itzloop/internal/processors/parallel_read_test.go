@@ -1,6 +1,8 @@
 package processors
 
 import (
+	"bytes"
+	"fmt"
 	"os"
 	"path"
 	"testing"
@@ -27,7 +29,7 @@ func TestParallelRead(t *testing.T) {
 					len:    24,
 				},
 				{
-					offset: 25,
+					offset: 24,
 					len:    21,
 				},
 			},
@@ -42,15 +44,60 @@ func TestParallelRead(t *testing.T) {
 					len:    21,
 				},
 				{
-					offset: 22,
-					len:    15,
+					offset: 21,
+					len:    16,
 				},
 				{
-					offset: 38,
+					offset: 37,
 					len:    8,
 				},
 			},
 		},
+		{
+			name:  "lines longer than look ahead",
+			data:  []byte("a\nbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb\nc\n"),
+			chunk: 2,
+			result: []chunk{
+				{
+					offset: 0,
+					len:    33,
+				},
+				{
+					offset: 33,
+					len:    2,
+				},
+			},
+		},
+		{
+			name:  "no newline at the end",
+			data:  []byte("aaa\nbbb\nccc"),
+			chunk: 2,
+			result: []chunk{
+				{
+					offset: 0,
+					len:    8,
+				},
+				{
+					offset: 8,
+					len:    3,
+				},
+			},
+		},
+		{
+			name:  "more chunks than bytes",
+			data:  []byte("a\nb\n"),
+			chunk: 10,
+			result: []chunk{
+				{
+					offset: 0,
+					len:    2,
+				},
+				{
+					offset: 2,
+					len:    2,
+				},
+			},
+		},
 	}
 
 	for _, tc := range table {
@@ -65,9 +112,9 @@ func TestParallelRead(t *testing.T) {
 
 			f.Close()
 
-			chunks, err := splitFile(p, tc.chunk, 10)
+			chunks, err := splitFile(p, tc.chunk, 10, 1024)
 			require.NoError(t, err)
-			assert.Len(t, chunks, len(tc.result))
+			require.Len(t, chunks, len(tc.result))
 
 			for i, chunk := range chunks {
 				assert.EqualValues(t, tc.result[i].offset, chunk.offset)
@@ -78,3 +125,105 @@ func TestParallelRead(t *testing.T) {
 	}
 
 }
+
+func TestSplitFileLongLines(t *testing.T) {
+	long := bytes.Repeat([]byte{'b'}, 10_000)
+	data := append(append([]byte("a;1.0\n"), long...), "\nc;2.0\n"...)
+	p := path.Join(t.TempDir(), "sample.txt")
+	require.NoError(t, os.WriteFile(p, data, 0o644))
+
+	t.Run("growing windows", func(t *testing.T) {
+		chunks, err := splitFile(p, 2, 4, 10_000)
+		require.NoError(t, err)
+		require.Len(t, chunks, 2)
+		assert.EqualValues(t, 6+len(long)+1, chunks[0].len)
+		assert.EqualValues(t, 6, chunks[1].len)
+	})
+
+	t.Run("too long", func(t *testing.T) {
+		_, err := splitFile(p, 2, 4, 1000)
+
+		var lerr *LineTooLongError
+		require.ErrorAs(t, err, &lerr)
+		assert.Equal(t, p, lerr.Path)
+		assert.EqualValues(t, len(data)/2, lerr.Offset)
+		assert.Equal(t, 1000, lerr.MaxLineLength)
+	})
+
+	t.Run("processor", func(t *testing.T) {
+		cfg := smallConfig()
+		cfg.ChunkCount = 2
+		cfg.MaxLineLength = 1000
+		processor, err := New("parallel-read", cfg)
+		require.NoError(t, err)
+
+		_, err = processor.Process(p)
+		var lerr *LineTooLongError
+		assert.ErrorAs(t, err, &lerr)
+	})
+}
+
+func TestLineEndLimit(t *testing.T) {
+	const maxLineLength = 100
+
+	table := []struct {
+		name  string
+		data  []byte
+		end   int64
+		error bool
+	}{
+		{name: "longest line", data: append(bytes.Repeat([]byte{'a'}, maxLineLength), '\n'), end: maxLineLength + 1},
+		{name: "one byte over", data: append(bytes.Repeat([]byte{'a'}, maxLineLength+1), '\n'), error: true},
+		{name: "newline in the last window", data: append(bytes.Repeat([]byte{'a'}, maxLineLength+20), '\n'), error: true},
+		{name: "longest last line", data: bytes.Repeat([]byte{'a'}, maxLineLength), end: maxLineLength},
+		{name: "last line one byte over", data: bytes.Repeat([]byte{'a'}, maxLineLength+1), error: true},
+	}
+
+	for _, tc := range table {
+		for _, window := range []int{1, 4, 7, maxLineLength, 1000} {
+			t.Run(fmt.Sprintf("%s/window=%d", tc.name, window), func(t *testing.T) {
+				end, err := lineEnd(bytes.NewReader(tc.data), 0, window, maxLineLength)
+				if tc.error {
+					var lerr *LineTooLongError
+					assert.ErrorAs(t, err, &lerr)
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, tc.end, end)
+			})
+		}
+	}
+}
+
+func FuzzSplitFile(f *testing.F) {
+	f.Add([]byte("aaa\nbbb\nc\nd\neeeeeeee\nff\ngggggg\nhhhhh\ni\nj\nk\nl\n"), uint8(3), uint8(10))
+	f.Add([]byte("a\nbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb\nc\n"), uint8(2), uint8(4))
+	f.Add([]byte("Hamburg;12.0\nIstanbul;6.2\nAbha;-23.0"), uint8(4), uint8(1))
+	f.Add([]byte("a\nb\n"), uint8(10), uint8(106))
+	f.Add([]byte("no newline at all"), uint8(2), uint8(3))
+	f.Add([]byte("Oslo;1.0\n\nOslo;2.0\n"), uint8(2), uint8(2))
+	f.Add([]byte("Oslo;1.0\nbad\nOslo;2.0\nbad"), uint8(3), uint8(1))
+	f.Add([]byte{}, uint8(1), uint8(1))
+
+	f.Fuzz(func(t *testing.T, data []byte, count, lookAheadBytes uint8) {
+		p := path.Join(t.TempDir(), "sample.txt")
+		require.NoError(t, os.WriteFile(p, data, 0o644))
+
+		chunks, err := splitFile(p, int(count), int(lookAheadBytes), len(data)+1)
+		require.NoError(t, err)
+
+		var (
+			pieces [][]byte
+			offset int64
+		)
+		for _, c := range chunks {
+			require.Equal(t, offset, c.offset, "chunks are not contiguous")
+			require.LessOrEqual(t, c.offset+c.len, int64(len(data)))
+
+			pieces = append(pieces, data[c.offset:c.offset+c.len])
+			offset += c.len
+		}
+
+		assertChunks(t, data, pieces)
+	})
+}
@@ -1,5 +1,58 @@
 package processors
 
+import (
+	"context"
+	"io"
+
+	"github.com/itzloop/1brc/types"
+)
+
 type Processor interface {
-    Process(p string) (result string, err error)
+	// Process is ProcessContext with context.Background().
+	Process(p string) (result *types.Result, err error)
+
+	// ProcessContext processes the file at p and stops early, returning
+	// ctx.Err(), once ctx is done.
+	ProcessContext(ctx context.Context, p string) (result *types.Result, err error)
+}
+
+// ReaderProcessor is implemented by processors that can work on a stream,
+// like stdin, instead of a file they can seek in.
+type ReaderProcessor interface {
+	ProcessReader(ctx context.Context, r io.Reader) (result *types.Result, err error)
+}
+
+// FilesProcessor is implemented by processors that can take several files in
+// a single run, sharing their readers and workers between them.
+type FilesProcessor interface {
+	// ProcessFiles merges the measurements of every file in paths into one
+	// Result. With perFile set Result.Files holds the result of each file.
+	ProcessFiles(ctx context.Context, paths []string, perFile bool) (result *types.Result, err error)
+}
+
+// ProcessFiles processes every file in paths with p. Processors that are not
+// a FilesProcessor go through the files one after another.
+func ProcessFiles(ctx context.Context, p Processor, paths []string, perFile bool) (*types.Result, error) {
+	if fp, ok := p.(FilesProcessor); ok {
+		return fp.ProcessFiles(ctx, paths, perFile)
+	}
+
+	result := types.NewResult(types.AgMeasureMap{}, 0)
+	if perFile {
+		result.Files = map[string]*types.Result{}
+	}
+
+	for _, path := range paths {
+		r, err := p.ProcessContext(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Merge(r)
+		if perFile {
+			result.Files[path] = r
+		}
+	}
+
+	return result, nil
 }
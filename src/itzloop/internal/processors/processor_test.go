@@ -0,0 +1,666 @@
+package processors
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itzloop/1brc/internal/generator"
+	"github.com/itzloop/1brc/types"
+)
+
+var testStations = []string{"Hamburg", "Istanbul", "Abha", "Zürich", "St. John's", "Oslo"}
+
+// testProcessors are the processors every test that goes through the
+// registry runs against.
+var testProcessors = []string{"parallel-read", "split-buf", "local-global", "naive"}
+
+// writeMeasurements writes rows lines of deterministic measurements into a
+// temp file and returns its path.
+func writeMeasurements(t testing.TB, rows int) string {
+	t.Helper()
+
+	var sb strings.Builder
+	for i := 0; i < rows; i++ {
+		m := (i*37)%1999 - 999
+		sign := ""
+		if m < 0 {
+			sign = "-"
+			m = -m
+		}
+		fmt.Fprintf(&sb, "%s;%s%d.%d\n", testStations[i%len(testStations)], sign, m/10, m%10)
+	}
+
+	return writeFile(t, []byte(sb.String()))
+}
+
+// writeFile writes data into a temp file and returns its path.
+func writeFile(t testing.TB, data []byte) string {
+	t.Helper()
+
+	p := path.Join(t.TempDir(), "measurements.txt")
+	require.NoError(t, os.WriteFile(p, data, 0o644))
+
+	return p
+}
+
+// smallConfig makes every processor do a lot of small reads and sends so
+// cancellation has a chance to land in the middle of a run.
+func smallConfig() Config {
+	cfg := DefaultConfig()
+	cfg.Processors = 4
+	cfg.ProcessorChanSize = 1
+	cfg.AggregatorChanSize = 1
+	cfg.ChunkSize = 256
+	cfg.ChunkCount = 16
+	cfg.ChunksChanSize = 1
+	cfg.ReaderCount = 4
+	cfg.SplitCount = 2
+	return cfg
+}
+
+// singleBlockConfig is a config where small inputs end up in a single block
+// handled by a single worker.
+func singleBlockConfig() Config {
+	cfg := DefaultConfig()
+	cfg.Processors = 1
+	cfg.ChunkSize = 1 << 20
+	cfg.ReadBuffers = 2
+	cfg.ChunkCount = 1
+	cfg.ReaderCount = 1
+	cfg.SplitCount = 1
+	return cfg
+}
+
+// assertNoLeak waits for the number of goroutines to go back to before.
+func assertNoLeak(t *testing.T, before int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before, "goroutines leaked")
+}
+
+func TestProcessContextCancel(t *testing.T) {
+	p := writeMeasurements(t, 20_000)
+
+	for _, name := range testProcessors {
+		t.Run(name+"/already cancelled", func(t *testing.T) {
+			before := runtime.NumGoroutine()
+
+			processor, err := New(name, smallConfig())
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			_, err = processor.ProcessContext(ctx, p)
+			assert.ErrorIs(t, err, context.Canceled)
+			assertNoLeak(t, before)
+		})
+
+		t.Run(name+"/deadline", func(t *testing.T) {
+			before := runtime.NumGoroutine()
+
+			processor, err := New(name, smallConfig())
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+			defer cancel()
+
+			_, err = processor.ProcessContext(ctx, p)
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+			assertNoLeak(t, before)
+		})
+
+		t.Run(name+"/cancel mid run", func(t *testing.T) {
+			before := runtime.NumGoroutine()
+
+			processor, err := New(name, smallConfig())
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(time.Millisecond, cancel)
+
+			// depending on timing the run may finish before cancel lands,
+			// either way nothing should be left running.
+			_, err = processor.ProcessContext(ctx, p)
+			if err != nil {
+				assert.ErrorIs(t, err, context.Canceled)
+			}
+			assertNoLeak(t, before)
+		})
+
+		t.Run(name+"/missing file", func(t *testing.T) {
+			before := runtime.NumGoroutine()
+
+			processor, err := New(name, smallConfig())
+			require.NoError(t, err)
+
+			_, err = processor.Process(path.Join(t.TempDir(), "missing.txt"))
+			assert.Error(t, err)
+			assertNoLeak(t, before)
+		})
+	}
+}
+
+func TestProcessorsAgree(t *testing.T) {
+	p := writeMeasurements(t, 20_000)
+
+	info, err := os.Stat(p)
+	require.NoError(t, err)
+
+	var expected string
+	for _, name := range testProcessors {
+		t.Run(name, func(t *testing.T) {
+			processor, err := New(name, smallConfig())
+			require.NoError(t, err)
+
+			result, err := processor.Process(p)
+			require.NoError(t, err)
+			assert.EqualValues(t, 20_000, result.Rows)
+			assert.EqualValues(t, info.Size(), result.Bytes)
+			assert.Len(t, result.Stations(), len(testStations))
+
+			if expected == "" {
+				expected = result.String()
+			}
+			assert.Equal(t, expected, result.String())
+		})
+	}
+}
+
+func TestProcessorStats(t *testing.T) {
+	p := writeMeasurements(t, 20_000)
+
+	filter, err := types.NewStationFilter(nil, []string{"A", "B", "S"}, "")
+	require.NoError(t, err)
+
+	for _, filtered := range []bool{false, true} {
+		for _, name := range testProcessors {
+			t.Run(fmt.Sprintf("%s/filtered=%t", name, filtered), func(t *testing.T) {
+				cfg := smallConfig()
+				cfg.Progress = NewProgress(InputSize([]string{p}))
+				if filtered {
+					cfg.Stations = filter
+				}
+				processor, err := New(name, cfg)
+				require.NoError(t, err)
+
+				result, err := processor.Process(p)
+				require.NoError(t, err)
+				assert.Equal(t, result.Rows, cfg.Progress.Rows())
+				if filtered {
+					assert.Less(t, result.Rows, int64(20_000))
+					assert.Positive(t, result.Rows)
+				}
+
+				stats := result.Stats
+				if name == "naive" {
+					assert.Nil(t, stats)
+					return
+				}
+				require.NotNil(t, stats)
+				require.Len(t, stats.Workers, cfg.Processors)
+
+				var readBytes, parsedBytes, rows int64
+				for _, r := range stats.Readers {
+					readBytes += r.Bytes
+				}
+				for _, w := range stats.Workers {
+					parsedBytes += w.Bytes
+					rows += w.Rows
+				}
+
+				assert.Equal(t, result.Bytes, readBytes)
+				assert.Equal(t, result.Bytes, parsedBytes)
+				assert.Equal(t, result.Rows, rows)
+				assert.Positive(t, stats.Wall)
+				assert.Positive(t, stats.Parse)
+				assert.GreaterOrEqual(t, stats.AggregatorBacklog, 1)
+			})
+		}
+	}
+}
+
+func TestProcessParseError(t *testing.T) {
+	p := writeMeasurements(t, 5_000)
+	data, err := os.ReadFile(p)
+	require.NoError(t, err)
+
+	// replace line 3001 with something we can't parse
+	lines := strings.SplitAfter(string(data), "\n")
+	offset := len(strings.Join(lines[:3000], ""))
+	lines[3000] = "Oslo;100.0\n"
+	require.NoError(t, os.WriteFile(p, []byte(strings.Join(lines, "")), 0o644))
+
+	for _, name := range testProcessors {
+		t.Run(name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+
+			processor, err := New(name, smallConfig())
+			require.NoError(t, err)
+
+			_, err = processor.Process(p)
+			var perr *ParseError
+			require.ErrorAs(t, err, &perr)
+			assert.Equal(t, p, perr.Path)
+			assert.EqualValues(t, offset, perr.Offset)
+			assert.Equal(t, 3001, perr.Line)
+			assert.Equal(t, "Oslo;100.0", perr.Raw)
+			assertNoLeak(t, before)
+		})
+	}
+}
+
+// TestProcessorsConformance runs the tricky rounding cases of
+// types.TestConformance through every processor.
+func TestProcessorsConformance(t *testing.T) {
+	rows := "a;-0.1\na;0.0\n" + // mean -0.05
+		"b;-0.0\n" + // negative zero
+		"c;0.2\nc;0.3\n" + // mean 0.25
+		"d;-1.2\nd;-1.3\n" + // mean -1.25
+		"e;99.9\ne;99.8\n" + // mean 99.85
+		"f;-5.1\nf;-5.2\n" + // mean -5.15
+		"g;-99.9\ng;99.9\n"
+	expected := "{a=-0.1/0.0/0.0, b=0.0/0.0/0.0, c=0.2/0.3/0.3, d=-1.3/-1.2/-1.2, e=99.8/99.9/99.9, f=-5.2/-5.1/-5.1, g=-99.9/0.0/99.9}"
+
+	p := path.Join(t.TempDir(), "measurements.txt")
+	require.NoError(t, os.WriteFile(p, []byte(rows), 0o644))
+
+	for _, name := range testProcessors {
+		t.Run(name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.ChunkSize = 1024
+			processor, err := New(name, cfg)
+			require.NoError(t, err)
+
+			result, err := processor.Process(p)
+			require.NoError(t, err)
+			assert.Equal(t, expected, result.String())
+			assert.Equal(t, expected, NaiveString(result))
+		})
+	}
+}
+
+// TestMeasurementGrammar checks that every processor takes the same
+// measurements, naive parses them its own way. The whole input fits in a
+// single block too, so lines never get lucky and land on a block boundary.
+func TestMeasurementGrammar(t *testing.T) {
+	valid := []string{"0.0", "-0.0", "1.2", "-1.2", "12.3", "-99.9", "99.9"}
+	invalid := []string{"1", "1.", ".5", "-.5", "1.00", "+1.0", "123.4", "001.0", "1,2", "1.a", "-", "", "NaN", "1.0;", ";1.0"}
+	invalidLines := []struct {
+		input string
+		line  int
+	}{
+		{input: "Oslo;1.0\n\nOslo;2.0\n", line: 2},
+		{input: "\n", line: 1},
+		{input: "Oslo;1.0\nbad\nOslo;2.0\n", line: 2},
+		{input: "Oslo;1.0\nOslo;2.0\nbad", line: 3},
+		{input: "Oslo;1.0\nBergen", line: 2},
+		{input: "bad\n", line: 1},
+		{input: "Oslo;1.0\r\nOslo;2.0\n", line: 1},
+		{input: "Oslo;1.0\nOslo;2.0\r\n", line: 2},
+		{input: "Oslo;1.0\n\r\n", line: 2},
+	}
+
+	mmapCfg := singleBlockConfig()
+	mmapCfg.Mmap = runtime.GOOS == "linux"
+
+	for _, name := range testProcessors {
+		for _, cfg := range []Config{smallConfig(), singleBlockConfig(), mmapCfg} {
+			if cfg.Mmap && name != "parallel-read" {
+				continue
+			}
+
+			t.Run(fmt.Sprintf("%s/processors=%d/mmap=%t", name, cfg.Processors, cfg.Mmap), func(t *testing.T) {
+				processor, err := New(name, cfg)
+				require.NoError(t, err)
+
+				for _, v := range valid {
+					_, err := processor.Process(writeFile(t, []byte("Oslo;"+v+"\n")))
+					assert.NoError(t, err, v)
+				}
+
+				for _, v := range invalid {
+					_, err := processor.Process(writeFile(t, []byte("Oslo;1.0\nOslo;"+v+"\n")))
+					var perr *ParseError
+					if assert.ErrorAs(t, err, &perr, "%q", v) {
+						assert.Equal(t, 2, perr.Line, v)
+					}
+				}
+
+				for _, tc := range invalidLines {
+					_, err := processor.Process(writeFile(t, []byte(tc.input)))
+					var perr *ParseError
+					if assert.ErrorAs(t, err, &perr, "%q", tc.input) {
+						assert.Equal(t, tc.line, perr.Line, tc.input)
+					}
+				}
+			})
+		}
+	}
+}
+
+// FuzzProcessors checks every processor against naive on arbitrary inputs
+// that fit in a single block: either they all fail to parse it or they all
+// agree on the result.
+func FuzzProcessors(f *testing.F) {
+	f.Add([]byte("Hamburg;12.0\nIstanbul;6.2\nAbha;-23.0"))
+	f.Add([]byte("Oslo;1.0\n\nOslo;2.0\n"))
+	f.Add([]byte("\n"))
+	f.Add([]byte("Oslo;1.0\nbad\nOslo;2.0\n"))
+	f.Add([]byte("Oslo;1.0\nOslo;2.0\nbad"))
+	f.Add([]byte("Oslo;1.0\nBergen"))
+	f.Add([]byte("a;b;1.0\n"))
+	f.Add([]byte("Oslo;1.0\r\nOslo;2.0\r\n"))
+	f.Add([]byte{})
+
+	cfg := singleBlockConfig()
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p := writeFile(t, data)
+
+		want, wantErr := NewNaiveProcessor(NaiveOpts{}).Process(p)
+		for _, name := range testProcessors {
+			processor, err := New(name, cfg)
+			require.NoError(t, err)
+
+			got, err := processor.Process(p)
+			if wantErr != nil {
+				require.Error(t, err, "%s: naive failed with %v", name, wantErr)
+				continue
+			}
+			require.NoError(t, err, name)
+			require.Empty(t, types.Compare(want, got), name)
+		}
+	})
+}
+
+func TestParallelReadMmap(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("mmap input is only supported on linux")
+	}
+
+	p := writeMeasurements(t, 20_000)
+
+	expected, err := New("parallel-read", smallConfig())
+	require.NoError(t, err)
+	expectedResult, err := expected.Process(p)
+	require.NoError(t, err)
+
+	cfg := smallConfig()
+	cfg.Mmap = true
+	processor, err := New("parallel-read", cfg)
+	require.NoError(t, err)
+
+	t.Run("same result", func(t *testing.T) {
+		result, err := processor.Process(p)
+		require.NoError(t, err)
+		assert.Equal(t, expectedResult.String(), result.String())
+		assert.Equal(t, expectedResult.Bytes, result.Bytes)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := processor.ProcessContext(ctx, p)
+		assert.ErrorIs(t, err, context.Canceled)
+		assertNoLeak(t, before)
+	})
+
+	t.Run("empty file", func(t *testing.T) {
+		empty := path.Join(t.TempDir(), "empty.txt")
+		require.NoError(t, os.WriteFile(empty, nil, 0o644))
+
+		result, err := processor.Process(empty)
+		require.NoError(t, err)
+		assert.Equal(t, "{}", result.String())
+	})
+}
+
+func TestProcessFiles(t *testing.T) {
+	shards := []string{
+		writeMeasurements(t, 20_000),
+		writeMeasurements(t, 3),
+		writeFile(t, nil),
+		writeFile(t, []byte("Oslo;-12.3\nAbha;45.6\n")),
+		writeFile(t, gzipMembersOf(t, []byte("Hamburg;99.9\n"))),
+	}
+
+	var all []byte
+	for _, p := range shards[:4] {
+		data, err := os.ReadFile(p)
+		require.NoError(t, err)
+		all = append(all, data...)
+	}
+	all = append(all, "Hamburg;99.9\n"...)
+
+	expected, err := NewSplitBufProcessor(SplitBufOpts{}).Process(writeFile(t, all))
+	require.NoError(t, err)
+
+	mmapCfg := smallConfig()
+	mmapCfg.Mmap = runtime.GOOS == "linux"
+
+	table := []struct {
+		name string
+		cfg  Config
+	}{
+		{name: "parallel-read", cfg: smallConfig()},
+		{name: "parallel-read", cfg: mmapCfg},
+		{name: "split-buf", cfg: smallConfig()},
+		{name: "local-global", cfg: smallConfig()},
+		{name: "naive", cfg: smallConfig()},
+	}
+
+	for _, tc := range table {
+		t.Run(fmt.Sprintf("%s/mmap=%t", tc.name, tc.cfg.Mmap), func(t *testing.T) {
+			processor, err := New(tc.name, tc.cfg)
+			require.NoError(t, err)
+
+			result, err := ProcessFiles(context.Background(), processor, shards, false)
+			require.NoError(t, err)
+			assert.Equal(t, expected.String(), result.String())
+			assert.Equal(t, expected.Rows, result.Rows)
+			assert.Equal(t, expected.Bytes, result.Bytes)
+			assert.Nil(t, result.Files)
+
+			result, err = ProcessFiles(context.Background(), processor, shards, true)
+			require.NoError(t, err)
+			assert.Equal(t, expected.String(), result.String())
+			require.Len(t, result.Files, len(shards))
+
+			for _, p := range shards {
+				single, err := processor.Process(p)
+				require.NoError(t, err)
+				assert.Equal(t, single.String(), result.Files[p].String(), p)
+				assert.Equal(t, single.Rows, result.Files[p].Rows, p)
+				assert.Equal(t, single.Bytes, result.Files[p].Bytes, p)
+			}
+		})
+	}
+
+	t.Run("parse error in second file", func(t *testing.T) {
+		bad := writeFile(t, []byte("Oslo;1.0\nOslo;100.0\n"))
+
+		for _, name := range testProcessors {
+			before := runtime.NumGoroutine()
+
+			processor, err := New(name, smallConfig())
+			require.NoError(t, err)
+
+			_, err = ProcessFiles(context.Background(), processor, []string{shards[0], bad}, false)
+			var perr *ParseError
+			require.ErrorAs(t, err, &perr, name)
+			assert.Equal(t, bad, perr.Path, name)
+			assert.Equal(t, 2, perr.Line, name)
+			assertNoLeak(t, before)
+		}
+	})
+}
+
+// TestDifferential checks every processor against naive on generated inputs.
+func TestDifferential(t *testing.T) {
+	stations, err := generator.UniqueStations(10_000)
+	require.NoError(t, err)
+
+	table := []struct {
+		name       string
+		opts       generator.Options
+		aggregates types.AgOptions
+	}{
+		{name: "413 stations", opts: generator.Options{Rows: 50_000, Seed: 1}},
+		{name: "10k stations", opts: generator.Options{Rows: 50_000, Seed: 2, Stations: stations}},
+		{name: "histograms", opts: generator.Options{Rows: 50_000, Seed: 3}, aggregates: types.AgOptions{Histogram: true}},
+		{name: "sketches", opts: generator.Options{Rows: 50_000, Seed: 4}, aggregates: types.AgOptions{Sketch: true}},
+	}
+
+	for _, tc := range table {
+		var buf bytes.Buffer
+		require.NoError(t, generator.Generate(context.Background(), &buf, tc.opts))
+		p := writeFile(t, buf.Bytes())
+
+		want, err := NewNaiveProcessor(NaiveOpts{Aggregates: tc.aggregates}).Process(p)
+		require.NoError(t, err)
+		require.EqualValues(t, tc.opts.Rows, want.Rows)
+
+		for _, name := range testProcessors {
+			t.Run(tc.name+"/"+name, func(t *testing.T) {
+				cfg := smallConfig()
+				cfg.Aggregates = tc.aggregates
+				processor, err := New(name, cfg)
+				require.NoError(t, err)
+
+				got, err := processor.Process(p)
+				require.NoError(t, err)
+				assert.Empty(t, types.Compare(want, got))
+				assert.Equal(t, NaiveString(want), got.String())
+				assert.Equal(t, want.Bytes, got.Bytes)
+			})
+		}
+	}
+}
+
+func TestStationFilter(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, generator.Generate(context.Background(), &buf, generator.Options{Rows: 50_000, Seed: 6}))
+	p := writeFile(t, buf.Bytes())
+
+	all, err := NewNaiveProcessor(NaiveOpts{}).Process(p)
+	require.NoError(t, err)
+
+	filter, err := types.NewStationFilter([]string{"Hamburg", "Istanbul"}, []string{"San "}, `^Z`)
+	require.NoError(t, err)
+
+	var stations []string
+	all.Range(func(station string, _ *types.AgMeasures) bool {
+		if filter.Match([]byte(station)) {
+			stations = append(stations, station)
+		}
+		return true
+	})
+	require.Greater(t, len(stations), 3)
+
+	for _, name := range testProcessors {
+		t.Run(name, func(t *testing.T) {
+			cfg := smallConfig()
+			cfg.Stations = filter
+			processor, err := New(name, cfg)
+			require.NoError(t, err)
+
+			got, err := processor.Process(p)
+			require.NoError(t, err)
+			require.Equal(t, stations, got.Stations())
+
+			var rows int64
+			for _, station := range stations {
+				want, _ := all.Get(station)
+				m, _ := got.Get(station)
+				assert.True(t, want.Equal(m), station)
+				rows += int64(want.Count)
+			}
+			assert.Equal(t, rows, got.Rows)
+			assert.Equal(t, all.Bytes, got.Bytes)
+		})
+	}
+}
+
+// TestSketchAccuracy checks the percentiles of sketches against the exact ones
+// of histograms.
+func TestSketchAccuracy(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, generator.Generate(context.Background(), &buf, generator.Options{Rows: 200_000, Seed: 5}))
+	p := writeFile(t, buf.Bytes())
+
+	exact, err := NewNaiveProcessor(NaiveOpts{Aggregates: types.AgOptions{Histogram: true}}).Process(p)
+	require.NoError(t, err)
+
+	for _, name := range testProcessors {
+		t.Run(name, func(t *testing.T) {
+			cfg := smallConfig()
+			cfg.Aggregates = types.AgOptions{Sketch: true}
+			processor, err := New(name, cfg)
+			require.NoError(t, err)
+
+			got, err := processor.Process(p)
+			require.NoError(t, err)
+
+			exact.Range(func(station string, want *types.AgMeasures) bool {
+				m, ok := got.Get(station)
+				require.True(t, ok, station)
+				d := got.Distributions[station]
+				require.NotNil(t, d, station)
+				require.Nil(t, d.Hist)
+
+				for _, q := range []float64{0.01, 0.25, 0.5, 0.9, 0.99, 1} {
+					w, _ := exact.Distributions[station].Quantile(q, want.Count)
+					g, ok := d.Quantile(q, m.Count)
+					require.True(t, ok)
+					assert.InDelta(t, w, g, types.SketchRelativeAccuracy*math.Abs(w)+1e-9, "%s q=%v", station, q)
+				}
+				return true
+			})
+		})
+	}
+}
+
+func TestLastLineWithoutNewline(t *testing.T) {
+	data, err := os.ReadFile(writeMeasurements(t, 5_000))
+	require.NoError(t, err)
+
+	want, err := NewNaiveProcessor(NaiveOpts{}).Process(writeFile(t, data))
+	require.NoError(t, err)
+
+	p := writeFile(t, bytes.TrimSuffix(data, []byte("\n")))
+
+	mmapCfg := smallConfig()
+	mmapCfg.Mmap = runtime.GOOS == "linux"
+
+	for _, name := range testProcessors {
+		for _, cfg := range []Config{smallConfig(), mmapCfg} {
+			t.Run(fmt.Sprintf("%s/mmap=%t", name, cfg.Mmap), func(t *testing.T) {
+				processor, err := New(name, cfg)
+				require.NoError(t, err)
+
+				got, err := processor.Process(p)
+				require.NoError(t, err)
+				assert.Empty(t, types.Compare(want, got))
+			})
+		}
+	}
+}
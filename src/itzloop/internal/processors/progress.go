@@ -0,0 +1,222 @@
+package processors
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReaderState is what a reader goroutine is busy with.
+type ReaderState int32
+
+const (
+	ReaderIdle    ReaderState = iota // waiting for a chunk or a buffer
+	ReaderReading                    // in a read call
+	ReaderSending                    // handing blocks to the workers
+	ReaderDone
+)
+
+func (s ReaderState) String() string {
+	switch s {
+	case ReaderIdle:
+		return "idle"
+	case ReaderReading:
+		return "reading"
+	case ReaderSending:
+		return "sending"
+	case ReaderDone:
+		return "done"
+	}
+
+	return fmt.Sprintf("ReaderState(%d)", int32(s))
+}
+
+// Progress is how far a run got. Processors update it once per read and once
+// per block, never per line, so a run being watched is as fast as one that
+// isn't. A nil *Progress is valid and ignores every update.
+type Progress struct {
+	bytes atomic.Int64
+	rows  atomic.Int64
+	total atomic.Int64
+
+	mu      sync.Mutex
+	readers []*readerProgress
+}
+
+type readerProgress struct {
+	state atomic.Int32
+	bytes atomic.Int64
+}
+
+func (rp *readerProgress) set(s ReaderState) {
+	rp.state.Store(int32(s))
+}
+
+// NewProgress returns a Progress for an input of total bytes, 0 if the size
+// isn't known up front.
+func NewProgress(total int64) *Progress {
+	p := &Progress{}
+	p.total.Store(total)
+	return p
+}
+
+// Bytes returns the number of input bytes read so far.
+func (p *Progress) Bytes() int64 {
+	return p.bytes.Load()
+}
+
+// Rows returns the number of measurements aggregated so far, rows skipped by
+// the station filter are not counted.
+func (p *Progress) Rows() int64 {
+	return p.rows.Load()
+}
+
+func (p *Progress) addBytes(n int64) {
+	if p != nil {
+		p.bytes.Add(n)
+	}
+}
+
+func (p *Progress) addRows(n int64) {
+	if p != nil {
+		p.rows.Add(n)
+	}
+}
+
+// startReaders replaces the readers of the last run with n new ones. The
+// returned readers are never nil, updating them is a single atomic store
+// whether anyone is watching or not.
+func (p *Progress) startReaders(n int) []*readerProgress {
+	readers := make([]*readerProgress, n)
+	for i := range readers {
+		readers[i] = &readerProgress{}
+	}
+
+	if p != nil {
+		p.mu.Lock()
+		p.readers = readers
+		p.mu.Unlock()
+	}
+
+	return readers
+}
+
+// Report writes a line about p to w every interval until stop is called.
+// stop writes one last line and returns once nothing writes to w anymore.
+func (p *Progress) Report(w io.Writer, interval time.Duration) (stop func()) {
+	var (
+		done = make(chan struct{})
+		wg   sync.WaitGroup
+	)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var (
+			start    = time.Now()
+			last     = start
+			lastRows int64
+		)
+		for {
+			select {
+			case now := <-ticker.C:
+				rows := p.Rows()
+				fmt.Fprintln(w, p.line(now.Sub(start), now.Sub(last), rows-lastRows))
+				last, lastRows = now, rows
+			case <-done:
+				now := time.Now()
+				fmt.Fprintln(w, p.line(now.Sub(start), now.Sub(last), p.Rows()-lastRows))
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// line formats p after elapsed time, with rows parsed during the last
+// interval for the rate.
+func (p *Progress) line(elapsed, interval time.Duration, rows int64) string {
+	var (
+		str   = strings.Builder{}
+		bytes = p.Bytes()
+		total = p.total.Load()
+	)
+
+	str.WriteString("progress: ")
+	if total > 0 {
+		fmt.Fprintf(&str, "%s / %s (%.1f%%)", formatBytes(bytes), formatBytes(total), 100*float64(bytes)/float64(total))
+	} else {
+		str.WriteString(formatBytes(bytes))
+	}
+
+	if interval > 0 {
+		fmt.Fprintf(&str, ", %.2fM rows/s", float64(rows)/interval.Seconds()/1e6)
+	}
+
+	// the average rate since the start is steadier than the last interval
+	if total > 0 && bytes > 0 && bytes < total {
+		eta := time.Duration(float64(elapsed) * float64(total-bytes) / float64(bytes))
+		fmt.Fprintf(&str, ", ETA %s", eta.Round(time.Second))
+	}
+
+	p.mu.Lock()
+	readers := p.readers
+	p.mu.Unlock()
+
+	if len(readers) > 0 {
+		str.WriteString(", readers:")
+		for i, r := range readers {
+			fmt.Fprintf(&str, " %d %s %s", i, ReaderState(r.state.Load()), formatBytes(r.bytes.Load()))
+			if i < len(readers)-1 {
+				str.WriteString(",")
+			}
+		}
+	}
+
+	return str.String()
+}
+
+// InputSize returns the total size of the files in paths, or 0 if any of them
+// is compressed and the number of bytes processors will read is unknown.
+func InputSize(paths []string) int64 {
+	var total int64
+	for _, p := range paths {
+		c, err := detectCompression(p)
+		if err != nil || c != compressionNone {
+			return 0
+		}
+
+		fInfo, err := os.Stat(p)
+		if err != nil {
+			return 0
+		}
+		total += fInfo.Size()
+	}
+
+	return total
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1e9:
+		return fmt.Sprintf("%.2f GB", float64(n)/1e9)
+	case n >= 1e6:
+		return fmt.Sprintf("%.1f MB", float64(n)/1e6)
+	case n >= 1e3:
+		return fmt.Sprintf("%.1f kB", float64(n)/1e3)
+	}
+
+	return fmt.Sprintf("%d B", n)
+}
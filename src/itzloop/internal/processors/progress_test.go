@@ -0,0 +1,86 @@
+package processors
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgress(t *testing.T) {
+	p := writeMeasurements(t, 20_000)
+
+	for _, name := range testProcessors {
+		t.Run(name, func(t *testing.T) {
+			cfg := smallConfig()
+			cfg.Progress = NewProgress(InputSize([]string{p}))
+			processor, err := New(name, cfg)
+			require.NoError(t, err)
+
+			stop := cfg.Progress.Report(io.Discard, time.Millisecond)
+			result, err := processor.Process(p)
+			stop()
+			require.NoError(t, err)
+			assert.Equal(t, result.Bytes, cfg.Progress.Bytes())
+			assert.Equal(t, result.Rows, cfg.Progress.Rows())
+
+			line := cfg.Progress.line(time.Second, time.Second, 0)
+			assert.Contains(t, line, "(100.0%)")
+			assert.NotContains(t, line, "ETA")
+			assert.NotContains(t, line, "reading")
+			assert.NotContains(t, line, "sending")
+			assert.Contains(t, line, "0 done")
+		})
+	}
+}
+
+func TestProgressLine(t *testing.T) {
+	p := NewProgress(4e9)
+	p.bytes.Store(1e9)
+	readers := p.startReaders(2)
+	readers[0].set(ReaderReading)
+	readers[0].bytes.Store(6e8)
+	readers[1].bytes.Store(4e8)
+
+	assert.Equal(t,
+		"progress: 1.00 GB / 4.00 GB (25.0%), 2.50M rows/s, ETA 30s, readers: 0 reading 600.0 MB, 1 idle 400.0 MB",
+		p.line(10*time.Second, 2*time.Second, 5_000_000),
+	)
+
+	assert.Equal(t, "progress: 512 B, 0.00M rows/s", func() string {
+		p := NewProgress(0)
+		p.addBytes(512)
+		return p.line(time.Second, time.Second, 0)
+	}())
+}
+
+func TestProgressReport(t *testing.T) {
+	var out bytes.Buffer
+	p := NewProgress(0)
+	stop := p.Report(&out, time.Hour)
+	p.addBytes(10)
+	stop()
+
+	assert.Equal(t, "progress: 10 B", strings.SplitN(strings.TrimSpace(out.String()), ",", 2)[0])
+}
+
+func TestInputSize(t *testing.T) {
+	a := writeFile(t, []byte("Hamburg;12.0\n"))
+	b := writeFile(t, []byte("Oslo;1.0\n"))
+	gz := writeFile(t, gzipMembersOf(t, []byte("Oslo;1.0\n")))
+
+	assert.EqualValues(t, 22, InputSize([]string{a, b}))
+	assert.Zero(t, InputSize([]string{a, gz}), "size of compressed input is unknown")
+	assert.Zero(t, InputSize([]string{a, a + ".missing"}))
+}
+
+func TestNilProgress(t *testing.T) {
+	var p *Progress
+	p.addBytes(1)
+	p.addRows(1)
+	assert.Len(t, p.startReaders(3), 3)
+}
@@ -0,0 +1,121 @@
+package processors
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/itzloop/1brc/constants"
+	"github.com/itzloop/1brc/types"
+)
+
+// Config holds every knob a registered processor can be tuned with. Each
+// processor only looks at the fields it cares about and ignores the rest.
+type Config struct {
+	Processors         int
+	ProcessorChanSize  int
+	AggregatorChanSize int
+
+	// ChunkSize is the size of each sequential read for the split-buf and
+	// local-global processors. ReadBuffers is how many of those split-buf
+	// keeps around for reuse.
+	ChunkSize   int
+	ReadBuffers int
+
+	// ChunkCount, ChunksChanSize, ReaderCount, LookAheadBytes,
+	// MaxLineLength, SplitCount and Mmap are only used by the parallel-read
+	// processor.
+	ChunkCount     int
+	ChunksChanSize int
+	ReaderCount    int
+	LookAheadBytes int
+	MaxLineLength  int
+	SplitCount     int
+	Mmap           bool
+
+	// Aggregates picks the opt-in per station aggregates, like histograms.
+	Aggregates types.AgOptions
+
+	// Stations, if set, limits a run to the stations it picks. The filter
+	// runs once per station, not per row: workers still hash the station
+	// name of every row while scanning for ';' and look it up in their map,
+	// where the decision is kept, but rows of other stations are never
+	// parsed or aggregated.
+	Stations *types.StationFilter `json:"-"`
+
+	Log *log.Logger `json:"-"`
+
+	// Progress, if set, is kept up to date while processors run.
+	Progress *Progress `json:"-"`
+}
+
+// DefaultConfig returns the values we have been running the processors with.
+func DefaultConfig() Config {
+	return Config{
+		Processors:         8,
+		ProcessorChanSize:  16,
+		AggregatorChanSize: 8,
+		ChunkSize:          1 * constants.GiB,
+		ReadBuffers:        3,
+		ChunkCount:         8,
+		ChunksChanSize:     8,
+		ReaderCount:        8,
+		LookAheadBytes:     106,
+		MaxLineLength:      1 * constants.MiB,
+		SplitCount:         4,
+	}
+}
+
+// Factory builds a processor from cfg.
+type Factory func(cfg Config) Processor
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a processor available by name. It panics if the name is
+// already taken, same as flag and database/sql do.
+func Register(name string, f Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if f == nil {
+		panic("processors: Register factory is nil")
+	}
+
+	if _, ok := registry[name]; ok {
+		panic("processors: Register called twice for " + name)
+	}
+
+	registry[name] = f
+}
+
+// New builds the processor registered as name.
+func New(name string, cfg Config) (Processor, error) {
+	registryMu.RLock()
+	f, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown processor %q, available processors are: %s", name, strings.Join(Names(), ", "))
+	}
+
+	return f(cfg), nil
+}
+
+// Names returns the registered processor names in sorted order.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
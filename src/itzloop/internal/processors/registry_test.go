@@ -0,0 +1,33 @@
+package processors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	assert.Subset(t, Names(), testProcessors)
+
+	table := []struct {
+		name     string
+		expected Processor
+	}{
+		{name: "parallel-read", expected: &ParallelReadProcessor{}},
+		{name: "split-buf", expected: &SplitBufProcessor{}},
+		{name: "local-global", expected: &LocalGlobalMapProcessor{}},
+		{name: "naive", expected: &NaiveProcessor{}},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := New(tc.name, DefaultConfig())
+			require.NoError(t, err)
+			assert.IsType(t, tc.expected, p)
+		})
+	}
+
+	_, err := New("does-not-exist", DefaultConfig())
+	assert.Error(t, err)
+}
@@ -2,16 +2,14 @@ package processors
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log"
-	"os"
 	"sync"
 	"time"
-	"unsafe"
 
-	"github.com/itzloop/1brc/constants"
 	"github.com/itzloop/1brc/types"
 	"github.com/itzloop/1brc/utils"
 )
@@ -20,10 +18,33 @@ type SplitBufOpts struct {
 	Processors         int
 	ProcessorChanSize  int
 	AggregatorChanSize int
+	ChunkSize          int
+	ReadBuffers        int
+	Aggregates         types.AgOptions
+	Stations           *types.StationFilter
 	Log                *log.Logger
+	Progress           *Progress
 }
+
+func init() {
+	Register("split-buf", func(cfg Config) Processor {
+		return NewSplitBufProcessor(SplitBufOpts{
+			Processors:         cfg.Processors,
+			ProcessorChanSize:  cfg.ProcessorChanSize,
+			AggregatorChanSize: cfg.AggregatorChanSize,
+			ChunkSize:          cfg.ChunkSize,
+			ReadBuffers:        cfg.ReadBuffers,
+			Aggregates:         cfg.Aggregates,
+			Stations:           cfg.Stations,
+			Log:                cfg.Log,
+			Progress:           cfg.Progress,
+		})
+	})
+}
+
 type SplitBufProcessor struct {
-	globalAg     map[string]*types.AgMeasures
+	globalAg     *aggregate
+	stats        *types.Stats
 	aggregatorWG sync.WaitGroup
 	processorWG  sync.WaitGroup
 	opts         SplitBufOpts
@@ -34,183 +55,315 @@ func NewSplitBufProcessor(opts SplitBufOpts) *SplitBufProcessor {
 		opts.Log = log.New(io.Discard, "", 0)
 	}
 
+	def := DefaultConfig()
+	if opts.Processors <= 0 {
+		opts.Processors = def.Processors
+	}
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = def.ChunkSize
+	}
+	if opts.ReadBuffers <= 1 { // the remainder holds on to one of them
+		opts.ReadBuffers = def.ReadBuffers
+	}
+
 	return &SplitBufProcessor{
-		globalAg:     map[string]*types.AgMeasures{},
+		globalAg:     newAggregate(opts.Aggregates),
 		aggregatorWG: sync.WaitGroup{},
 		processorWG:  sync.WaitGroup{},
 		opts:         opts,
 	}
 }
 
-func (sbp *SplitBufProcessor) Process(p string) (result string, err error) {
+func (sbp *SplitBufProcessor) Process(p string) (result *types.Result, err error) {
+	return sbp.ProcessContext(context.Background(), p)
+}
+
+func (sbp *SplitBufProcessor) ProcessContext(ctx context.Context, p string) (result *types.Result, err error) {
+	input, err := openInput(p, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pen file: %w\n", err)
+	}
+
+	defer func() {
+		if err := input.Close(); err != nil {
+			sbp.opts.Log.Printf("error when trying to close the file: %v\n", err)
+		}
+	}()
+
+	result, err = sbp.ProcessReader(ctx, input)
+	if err != nil {
+		return nil, fillLine(err, p)
+	}
+
+	return result, nil
+}
+
+// ProcessReader processes measurements read from r, which can be a pipe or
+// anything else that can't seek. At most ReadBuffers buffers of ChunkSize
+// bytes are in use at any time, no matter how long r is. Use StreamChunkSize
+// for pipes, a chunk is only handed to the workers once it is full.
+func (sbp *SplitBufProcessor) ProcessReader(ctx context.Context, r io.Reader) (result *types.Result, err error) {
+	// the first worker to fail cancels ctx with its error and everyone else
+	// winds down.
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	sbp.globalAg = newAggregate(sbp.opts.Aggregates)
+	sbp.stats = types.NewStats(1, sbp.opts.Processors)
+
 	// create processrors
-	agCh := make(chan map[string]*types.AgMeasures, sbp.opts.AggregatorChanSize)
+	agCh := make(chan *utils.CustomMap, sbp.opts.AggregatorChanSize)
 	sbp.aggregatorWG.Add(1)
 	go sbp.aggregator(agCh)
 
 	sbp.processorWG.Add(sbp.opts.Processors)
-	ch := make(chan []byte, sbp.opts.ProcessorChanSize)
+	ch := make(chan block, sbp.opts.ProcessorChanSize)
 	for i := 0; i < sbp.opts.Processors; i++ {
 		i := i
-		go sbp.process(i, ch, agCh)
+		go sbp.process(ctx, cancel, i, ch, agCh)
 	}
 
-	input, err := os.Open(p)
-	if err != nil {
-		return "", fmt.Errorf("failed to pen file: %w\n", err)
-	}
+	// read the input in chunks
+	start := time.Now()
+	pool := newBufferPool(sbp.opts.ReadBuffers, sbp.opts.ChunkSize)
+	rs := &sbp.stats.Readers[0]
+	rp := sbp.opts.Progress.startReaders(1)[0]
+	defer rp.set(ReaderDone)
+	var (
+		remainder    []byte  // partial line at the end of the last read
+		prev         *buffer // holds on to remainder until it is copied
+		overallBytes int64
+	)
+	for ctx.Err() == nil {
+		buf, err := pool.get(ctx)
+		if err != nil {
+			break
+		}
 
-	defer func() {
-		if err = input.Close(); err != nil {
-			sbp.opts.Log.Printf("error when trying to close the file: %v\n", err)
+		// prepend reminder
+		n := copy(buf.data, remainder)
+		if prev != nil {
+			prev.release()
+			prev = nil
 		}
-	}()
 
-	// read the file in chunks
-	start := time.Now()
-	chunckSize := 1 * constants.GiB
-	var remainder []byte
-	overallBytes := 0
-	// for count := 0; count < 2; count++ {
-	for {
 		start := time.Now()
-		//n, err := input.ReadAt(buf, int64((7+count)*1073741824))
-		buf := make([]byte, chunckSize+len(remainder))
-		n, err := input.Read(buf[len(remainder):])
+		rp.set(ReaderReading)
+		read, err := io.ReadFull(r, buf.data[n:])
 		end := time.Since(start)
-		overallBytes += n
-		if err != nil {
-			if errors.Is(err, io.EOF) {
-				sbp.opts.Log.Println("EOF")
+		overallBytes += int64(read)
+		rp.bytes.Add(int64(read))
+		sbp.opts.Progress.addBytes(int64(read))
+		rs.Read += end
+		rs.Reads++
+		rs.Bytes += int64(read)
+		eof := errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+		if err != nil && !eof {
+			buf.release()
+			cancel(fmt.Errorf("failed to read the input: %w", err))
+			break
+		}
+		sbp.opts.Log.Printf("it took %s to read %d bytes\n", end.String(), read)
+
+		data := buf.data[:n+read]
+		b := block{offset: overallBytes - int64(len(data)), owner: buf}
+		if eof {
+			// the last line doesn't have to end with a newline, ReadFull
+			// came up short so there is room to add one.
+			if len(data) > 0 && data[len(data)-1] != '\n' {
+				data = buf.data[:len(data)+1]
+				data[len(data)-1] = '\n'
+			}
+			b.buf = data
+			remainder = nil
+		} else {
+			// find new remainder on the new buffer
+			i := bytes.LastIndexByte(data, '\n')
+			if i == -1 {
+				buf.release()
+				cancel(fmt.Errorf("found no newline in %d bytes at offset %d, lines must be shorter than the chunk size", len(data), b.offset))
 				break
 			}
 
-			return "", fmt.Errorf("failed to read the input:  %w\n", err)
+			b.buf = data[:i+1]
+			remainder = data[i+1:]
+			sbp.opts.Log.Printf("found %d bytes remainder buf[%d:%d]=%s\n", len(remainder), i+1, len(data), string(remainder))
 		}
-		sbp.opts.Log.Printf("it took %s to read %d bytes\n", end.String(), n)
-
-		// prepend reminder
-		copy(buf, remainder)
 
-		// find new remainder on the new buffer
-	findRemainder:
-		for i := len(buf) - 1; i >= 0; i-- {
-			switch buf[i] {
-			case '\n':
-				remainder = buf[i+1:]
-				buf = buf[:i+1]
-				sbp.opts.Log.Printf("found %d bytes remainder buf[%d:%d]=%s\n", len(remainder), i+1, len(buf), string(remainder))
-				break findRemainder
+		// split buf
+		blocks := splitBlock(b, sbp.opts.Processors)
+		buf.refs.Add(int32(len(blocks)))
+		start = time.Now()
+		rp.set(ReaderSending)
+	send:
+		for i, b := range blocks {
+			select {
+			case ch <- b:
+			case <-ctx.Done():
+				for _, b := range blocks[i:] {
+					b.release()
+				}
+				break send
 			}
 		}
+		rs.Send += time.Since(start)
+		rp.set(ReaderIdle)
 
-		// split buf
-		bufs := splitbuf(buf, sbp.opts.Processors)
-		for _, buf := range bufs {
-			ch <- buf
+		if len(remainder) > 0 {
+			prev = buf
+		} else {
+			buf.release()
+		}
+
+		if eof {
+			sbp.opts.Log.Println("EOF")
+			break
 		}
 	}
 
+	if prev != nil {
+		prev.release()
+	}
+
 	sbp.opts.Log.Printf("it took %s to fully read %d bytes\n", time.Since(start), overallBytes)
-	close(ch)
 
+	// workers drain whatever is left in ch and the aggregator drains agCh,
+	// so closing both in order is enough to stop everything.
+	close(ch)
 	sbp.processorWG.Wait()
 	close(agCh)
 	sbp.aggregatorWG.Wait()
 
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+
 	sbp.opts.Log.Printf("it took %s to fully process %d bytes\n", time.Since(start), overallBytes)
-	return types.AgMeasureMap(sbp.globalAg).SortedString(), nil
+	sbp.stats.Wall = time.Since(start)
+	sbp.stats.Sum()
+	result = sbp.globalAg.result(overallBytes)
+	result.Stats = sbp.stats
+	return result, nil
 }
 
-func (sbp *SplitBufProcessor) aggregator(agCh <-chan map[string]*types.AgMeasures) {
+func (sbp *SplitBufProcessor) aggregator(agCh <-chan *utils.CustomMap) {
 	defer sbp.aggregatorWG.Done()
 	sbp.opts.Log.Println("aggregator start")
 	start := time.Now()
 	for localAg := range agCh {
 		start := time.Now()
-		for k, v := range localAg {
-			agM, ok := sbp.globalAg[k]
-			if !ok {
-				agM = &types.AgMeasures{
-					Min: 100,
-					Max: -100,
-				}
-				sbp.globalAg[k] = agM
-			}
-
-			agM.Max = max(agM.Max, v.Max)
-			agM.Min = min(agM.Min, v.Min)
-			agM.Total += float64(v.Total)
-			agM.Count += v.Count
-		}
+		sbp.stats.AggregatorBacklog = max(sbp.stats.AggregatorBacklog, len(agCh)+1)
+		sbp.globalAg.merge(localAg)
 		dd := time.Since(start)
-		sbp.opts.Log.Printf("aggregator: it took %s to aggregate %d results\n", dd.String(), len(localAg))
+		sbp.stats.Aggregate += dd
+		sbp.opts.Log.Printf("aggregator: it took %s to aggregate %d results\n", dd.String(), localAg.Len())
 	}
 
 	sbp.opts.Log.Printf("aggregator: it took %s to fully aggregate all results\n", time.Since(start))
 }
 
-func (sbp *SplitBufProcessor) process(id int, ch <-chan []byte, resultsCh chan<- map[string]*types.AgMeasures) {
+func (sbp *SplitBufProcessor) process(ctx context.Context, cancel context.CancelCauseFunc, id int, ch <-chan block, resultsCh chan<- *utils.CustomMap) {
 	defer sbp.processorWG.Done()
-	for buf := range ch {
-		ag := map[string]*types.AgMeasures{}
+
+	// every worker aggregates into its own map for the whole run and hands it
+	// to the aggregator once ch is closed.
+	ag := utils.NewCustomMapWith(utils.MaxStations, sbp.opts.Aggregates)
+	ag.SetFilter(sbp.opts.Stations)
+	ws := &sbp.stats.Workers[id]
+blocks:
+	for {
+		waitStart := time.Now()
+		b, ok := <-ch
+		if !ok {
+			break
+		}
+		ws.Wait += time.Since(waitStart)
+
+		if ctx.Err() != nil {
+			b.release()
+			continue // drain ch so the reader never blocks
+		}
+
+		buf := b.buf
 		i := 0
-		bol := 0  // begining of line
-		eost := 0 // end of station name
+		bol := 0              // begining of line
+		eost := -1            // end of station name, < bol until the line has a ';'
+		h := utils.HashOffset // hash of the line so far
+		var stHash uint64     // hash of the station name
 		start := time.Now()
 		totalMeasurements := 0
 		for i = 0; i < len(buf); i++ {
 			switch buf[i] {
 			case ';': // means we reached the end of station name
-				eost = i
+				if eost < bol { // the first one, like strings.Cut
+					eost = i
+					stHash = h
+				}
 			case '\n':
-				if eost < bol {
-					continue
+				h = utils.HashOffset
+				if eost < bol { // blank line or no ';'
+					cancel(newParseError(b, bol, i, utils.ErrInvalidMeasurement))
+					b.release()
+					continue blocks
 				}
 				// buf[bol:eost]: station name
 				// buf[eost + 1:i]: measurement
-				m, err := utils.BtofV2(buf[eost+1 : i])
+				st, dist := ag.Select(buf[bol:eost], stHash)
+				if st == nil { // skipped by the station filter, not even parsed
+					bol = i + 1
+					continue
+				}
+				m, err := utils.ParseTenths(buf[eost+1 : i])
 				if err != nil {
-					sbp.opts.Log.Panicf("worker %d: failed to parse %v=buf[%d:%d]=%s, eost=%d to float: %v\n", id, buf[bol:i+1], bol, i+1, string(buf[bol:i]), eost, err)
+					sbp.opts.Log.Printf("worker %d: failed to parse %v=buf[%d:%d]=%s, eost=%d to tenths: %v\n", id, buf[bol:i+1], bol, i+1, string(buf[bol:i]), eost, err)
+					cancel(newParseError(b, bol, i, err))
+					b.release()
+					continue blocks
 				}
-				stNameSubSlice := buf[bol:eost]
-				stName := unsafe.String(&stNameSubSlice[0], len(stNameSubSlice))
-				agM, ok := ag[stName]
-				if !ok {
-					agM = &types.AgMeasures{
-						Min: 100,
-						Max: -100,
-					}
-					ag[stName] = agM
+				st.Add(m)
+				if dist != nil { // only with opt-in aggregates
+					dist.Add(m)
 				}
 
-				agM.Max = max(agM.Max, m)
-				agM.Min = min(agM.Min, m)
-				agM.Total += float64(m)
-				agM.Count++
-
 				totalMeasurements++
 				bol = i + 1 // set bol to be start of next line
+			default:
+				h = utils.HashByte(h, buf[i])
 			}
 		}
 
 		end := time.Since(start)
 		sbp.opts.Log.Printf("worker %d: it took %s to proccess %d measurements\n", id, end, totalMeasurements)
+		ws.Parse += end
+		ws.Bytes += int64(len(buf))
+		ws.Rows += int64(totalMeasurements)
+		ws.Blocks++
+		sbp.opts.Progress.addRows(int64(totalMeasurements))
+
+		b.release()
+	}
 
+	if ctx.Err() == nil {
 		resultsCh <- ag
 	}
 }
 
+// splitbuf splits buf into about count pieces of about the same size. Every
+// piece but the last ends right after a newline, the last one ends where buf
+// does.
 func splitbuf(buf []byte, count int) [][]byte {
 	n := len(buf)
 	remainder := 0
-	chunkBytes := n / count
+	chunkBytes := max(n/max(count, 1), 1)
 	var chunks [][]byte
 
 	for i := 0; i < n; i += chunkBytes + remainder {
 		remainder = 0
 		if i+chunkBytes < n {
 			remainder = bytes.IndexByte(buf[i+chunkBytes-1:], '\n')
+			if remainder == -1 { // no newline left, the rest is one piece
+				remainder = n - i - chunkBytes
+			}
 		} else {
 			chunkBytes = n - i
 		}
@@ -1,7 +1,18 @@
 package processors
 
 import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"runtime"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSplitbuf(t *testing.T) {
@@ -89,3 +100,121 @@ func TestSplitbuf(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitBufProcessReader(t *testing.T) {
+	p := writeMeasurements(t, 5_000)
+	data, err := os.ReadFile(p)
+	require.NoError(t, err)
+
+	expected, err := NewSplitBufProcessor(SplitBufOpts{}).Process(p)
+	require.NoError(t, err)
+
+	opts := SplitBufOpts{
+		Processors:  3,
+		ChunkSize:   128,
+		ReadBuffers: 2,
+	}
+
+	t.Run("one byte at a time", func(t *testing.T) {
+		result, err := NewSplitBufProcessor(opts).ProcessReader(context.Background(), iotest.OneByteReader(bytes.NewReader(data)))
+		require.NoError(t, err)
+		assert.Equal(t, expected.String(), result.String())
+		assert.Equal(t, expected.Rows, result.Rows)
+		assert.EqualValues(t, len(data), result.Bytes)
+	})
+
+	t.Run("pipe", func(t *testing.T) {
+		r, w := io.Pipe()
+		go func() {
+			for i := 0; i < len(data); i += 1000 {
+				w.Write(data[i:min(i+1000, len(data))])
+			}
+			w.Close()
+		}()
+
+		result, err := NewSplitBufProcessor(opts).ProcessReader(context.Background(), r)
+		require.NoError(t, err)
+		assert.Equal(t, expected.String(), result.String())
+	})
+
+	t.Run("no trailing newline", func(t *testing.T) {
+		result, err := NewSplitBufProcessor(opts).ProcessReader(context.Background(), bytes.NewReader(data[:len(data)-1]))
+		require.NoError(t, err)
+		assert.Equal(t, expected.String(), result.String())
+	})
+
+	t.Run("line longer than chunk size", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+
+		long := strings.Repeat("x", 200) + ";1.0\n"
+		_, err := NewSplitBufProcessor(opts).ProcessReader(context.Background(), strings.NewReader(long))
+		assert.Error(t, err)
+		assertNoLeak(t, before)
+	})
+
+	t.Run("parse error offset", func(t *testing.T) {
+		bad := append(bytes.Clone(data), []byte("Oslo;1\n")...)
+		_, err := NewSplitBufProcessor(opts).ProcessReader(context.Background(), bytes.NewReader(bad))
+
+		var perr *ParseError
+		require.ErrorAs(t, err, &perr)
+		assert.EqualValues(t, len(data), perr.Offset)
+		assert.Equal(t, "Oslo;1", perr.Raw)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+
+		r, w := io.Pipe()
+		go w.Write(data[:1000]) // never closed, only cancellation ends this run
+
+		// a blocked Read can't be interrupted, whoever cancels has to close
+		// the input as well
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, func() {
+			cancel()
+			w.CloseWithError(context.Canceled)
+		})
+
+		_, err := NewSplitBufProcessor(opts).ProcessReader(ctx, r)
+		assert.ErrorIs(t, err, context.Canceled)
+		assertNoLeak(t, before)
+	})
+}
+
+// assertChunks checks the properties readers rely on: chunks put back
+// together are data, none is empty and every chunk ends on a newline, except
+// the last one when data doesn't.
+func assertChunks(t *testing.T, data []byte, chunks [][]byte) {
+	t.Helper()
+
+	require.Equal(t, data, bytes.Join(chunks, nil), "chunks don't add up to the input")
+
+	for i, c := range chunks {
+		require.NotEmpty(t, c, "chunk %d is empty", i)
+
+		if i < len(chunks)-1 || bytes.HasSuffix(data, []byte{'\n'}) {
+			require.Equal(t, byte('\n'), c[len(c)-1], "chunk %d doesn't end on a newline: %q", i, c)
+		}
+	}
+}
+
+func FuzzSplitbuf(f *testing.F) {
+	f.Add([]byte("aaa\nbbbbb\nc\nd\ne\nffff\n"), 3)
+	f.Add([]byte("aaa\nbbbbb\nc\nd\ne\nffff\ngg\n"), 8)
+	f.Add([]byte("aaa\nbbb"), 2)
+	f.Add([]byte("a\n"), 5)
+	f.Add([]byte("no newline at all"), 4)
+	f.Add([]byte("\n\n\n"), 2)
+	f.Add([]byte("Oslo;1.0\n\nOslo;2.0\n"), 2)
+	f.Add([]byte("Oslo;1.0\nbad\nOslo;2.0\nbad"), 3)
+	f.Add([]byte{}, 1)
+
+	f.Fuzz(func(t *testing.T, data []byte, count int) {
+		if count < -1 || count > 1024 {
+			t.Skip()
+		}
+
+		assertChunks(t, data, splitbuf(data, count))
+	})
+}
@@ -1,16 +1,19 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"runtime/pprof"
-	"runtime/trace"
+	"os/signal"
+	"strings"
 	"time"
 
+	"github.com/itzloop/1brc/internal/experiment"
 	"github.com/itzloop/1brc/internal/processors"
+	"github.com/itzloop/1brc/types"
 )
 
 type AgMeasures struct {
@@ -20,17 +23,55 @@ type AgMeasures struct {
 	Count int
 }
 
-var (
-	processorCount     = 8
-	processorChanSize  = 16
-	aggregatorChanSize = 8
-)
-
 func main() {
-	inputPath := flag.String("i", "/home/loop/p/1brc/measurements.txt", "path to input file")
-	cpuProf := flag.Bool("cpu", false, "run pprof cpu profiling")
-	heapProf := flag.Bool("heap", false, "run pprof heap profiling")
-	traceProf := flag.Bool("trace", false, "run trace profiling")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "generate":
+			if err := generate(os.Args[2:]); err != nil {
+				log.Fatalln(err)
+			}
+			return
+		case "verify":
+			if err := verify(os.Args[2:]); err != nil {
+				log.Fatalln(err)
+			}
+			return
+		case "bench":
+			if err := runBench(os.Args[2:]); err != nil {
+				log.Fatalln(err)
+			}
+			return
+		case "bench-run":
+			if err := runBenchRun(os.Args[2:]); err != nil {
+				log.Fatalln(err)
+			}
+			return
+		}
+	}
+
+	cfg := processors.DefaultConfig()
+	inputPath := flag.String("i", "/home/loop/p/1brc/measurements.txt", "path to input file, glob or directory, gzip and zstd files are decompressed on the fly, - reads from stdin. More inputs can follow the flags")
+	perFile := flag.Bool("per-file", false, "print the result of every input file before the merged one, as a file column or key outside of the 1brc format")
+	runStats := flag.Bool("run-stats", false, "print where the processor spent its time to stderr")
+	progressInterval := flag.Duration("progress", 0, "print progress to stderr at this interval, 0 turns it off")
+	format := "1brc"
+	formatUsage := fmt.Sprintf("output format, one of: %s", strings.Join(types.Formats(), "|"))
+	flag.StringVar(&format, "o", format, formatUsage)
+	flag.StringVar(&format, "format", format, formatUsage)
+	stats := flag.String("stats", "", fmt.Sprintf("comma separated stats written for every station, out of: %s. Defaults to the usual ones of the output format", strings.Join(types.Columns(), ",")))
+	sortBy := flag.String("sort", "", fmt.Sprintf("order stations by one of: %s, add :desc for the largest first, like mean:desc. Defaults to by station name", strings.Join(types.SortKeys(), "|")))
+	top := flag.Int("top", 0, "only write the first N stations after sorting, 0 writes all of them")
+	processorName := flag.String("processor", "parallel-read", fmt.Sprintf("processor to use, one of: %s", strings.Join(processors.Names(), "|")))
+	configFlags(flag.CommandLine, &cfg)
+	stationFilter := stationFlags(flag.CommandLine)
+	var profiles experiment.Profiles
+	flag.BoolVar(&profiles.CPU, "cpu", false, "write a cpu profile")
+	flag.BoolVar(&profiles.Heap, "heap", false, "write a heap profile")
+	flag.BoolVar(&profiles.Trace, "trace", false, "write an execution trace")
+	flag.BoolVar(&profiles.Mutex, "mutex", false, "write a mutex contention profile")
+	flag.BoolVar(&profiles.Block, "block", false, "write a blocking profile")
+	profileDir := flag.String("profile-dir", "profiles", "directory experiments are written to")
+	experimentName := flag.String("experiment", "", "name of the experiment, profiles and a meta.json go to <profile-dir>/<experiment>/<start time>. Defaults to the processor name, turns on every profile unless some are picked")
 	disableLog := flag.Bool("disable-log", false, "disable logging")
 	flag.Parse()
 
@@ -38,85 +79,214 @@ func main() {
 		log.SetOutput(io.Discard)
 	}
 
-	now := time.Now()
+	var (
+		columns []string
+		err     error
+	)
+	if *stats != "" {
+		columns, err = types.ParseColumns(*stats)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	query, err := types.ParseQuery(*sortBy, *top)
+	if err != nil {
+		log.Fatalln(err)
+	}
 
-	if *cpuProf {
-		n := fmt.Sprintf("cpu_prof-%s.pb.gz", now.Format("2006-01-02T15-04"))
-		log.Println("running cpu profiler...")
-		log.Printf("cpu profiling data will be saved in %s\n", n)
+	cfg.Stations, err = stationFilter()
+	if err != nil {
+		log.Fatalln(err)
+	}
 
-		cpuProf, err := os.Create(n)
+	if types.QuantileColumns(columns) && !cfg.Aggregates.Sketch {
+		cfg.Aggregates.Histogram = true
+	}
+
+	encoder, err := types.NewEncoder(format, columns...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var paths []string
+	if *inputPath != "-" {
+		paths, err = processors.ExpandPaths(append([]string{*inputPath}, flag.Args()...)...)
 		if err != nil {
-			log.Panicf("failed to create file [%s]: %v\n", n, err)
+			log.Fatalln(err)
 		}
+	}
 
-		defer cpuProf.Close()
+	if *inputPath == "-" && !isFlagSet(flag.CommandLine, "chunk-size") {
+		cfg.ChunkSize = processors.StreamChunkSize
+	}
 
-		if err := pprof.StartCPUProfile(cpuProf); err != nil {
-			log.Panicf("failed to start cpu profiler: %v\n", err)
-		}
+	if *progressInterval > 0 {
+		cfg.Progress = processors.NewProgress(processors.InputSize(paths))
+	}
 
-		defer pprof.StopCPUProfile()
+	cfg.Log = log.Default()
+	processor, err := processors.New(*processorName, cfg)
+	if err != nil {
+		log.Fatalln(err)
 	}
 
-	if *traceProf {
-		n := fmt.Sprintf("trace-%s.trace", now.Format("2006-01-02T15-04"))
-		log.Println("running trace profiler...")
-		log.Printf("trace data will be saved in %s\n", n)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-		traceProf, err := os.Create(n)
-		if err != nil {
-			log.Panicf("failed to create file [%s]: %v\n", n, err)
+	var exp *experiment.Experiment
+	// fatal is log.Fatalln that stops the experiment first, os.Exit skips
+	// the deferred calls and would leave the profiles half written.
+	fatal := func(err error) {
+		if exp != nil {
+			if stopErr := exp.Fail(err); stopErr != nil {
+				log.Printf("failed to write profiling data: %v\n", stopErr)
+			}
 		}
+		log.Fatalln(err)
+	}
 
-		defer traceProf.Close()
+	if profiles.Any() || *experimentName != "" {
+		if *experimentName == "" {
+			*experimentName = *processorName
+		}
+		if !profiles.Any() {
+			profiles = experiment.All()
+		}
 
-		if err := trace.Start(traceProf); err != nil {
-			log.Panicf("failed to start cpu profiler: %v\n", err)
+		inputs := paths
+		if *inputPath == "-" {
+			inputs = []string{"-"}
 		}
 
-		defer trace.Stop()
+		exp, err = experiment.Start(*profileDir, *experimentName, profiles, experiment.Meta{
+			Processor: *processorName,
+			Config:    cfg,
+			Args:      os.Args[1:],
+			Inputs:    experiment.Inputs(inputs),
+		})
+		if err != nil {
+			log.Fatalln(err)
+		}
+		log.Printf("profiling data will be saved in %s\n", exp.Dir)
 	}
 
-	// result, err := processors.NewSplitBufProcessor(processors.SplitBufOpts{
-	// 	Processors:         processorCount,
-	// 	ProcessorChanSize:  processorChanSize,
-	// 	AggregatorChanSize: aggregatorChanSize,
-	// 	Log:                log.Default(),
-	// }).Process(*inputPath)
+	stopProgress := func() {}
+	if cfg.Progress != nil {
+		stopProgress = cfg.Progress.Report(os.Stderr, *progressInterval)
+	}
 
-	result, err := processors.NewParallelReadProcessor(processors.ParallelReadOpts{
-		Processors:         processorCount,
-		ProcessorChanSize:  processorChanSize,
-		AggregatorChanSize: aggregatorChanSize,
-		Log:                log.Default(),
-	}).Process(*inputPath)
+	start := time.Now()
 
-	// result, err := processors.NewLocalGlobalMapProcessor(processors.LocalGlobalMapOpts{
-	// 	Processors:         processorCount,
-	// 	ProcessorChanSize:  processorChanSize,
-	// 	AggregatorChanSize: aggregatorChanSize,
-	// 	Log:                log.Default(),
-	// }).Process(*inputPath)
+	var result *types.Result
+	if *inputPath == "-" {
+		rp, ok := processor.(processors.ReaderProcessor)
+		if !ok {
+			log.Printf("%s can't read from stdin, using split-buf instead\n", *processorName)
+			splitBuf, _ := processors.New("split-buf", cfg)
+			rp = splitBuf.(processors.ReaderProcessor)
+		}
 
+		result, err = rp.ProcessReader(ctx, os.Stdin)
+	} else {
+		result, err = processors.ProcessFiles(ctx, processor, paths, *perFile)
+	}
+	stopProgress()
 	if err != nil {
-		log.Panicln(err)
+		fatal(err)
+	}
+
+	if *runStats && result.Stats != nil {
+		fmt.Fprint(os.Stderr, result.Stats)
 	}
 
-	fmt.Println(result)
+	if exp != nil {
+		exp.Time("process", time.Since(start))
+		exp.Meta.Stats = result.Stats
+		start = time.Now()
+	}
 
-	if *heapProf {
-		n := fmt.Sprintf("heap_prof-%s.pb.gz", now.Format("2006-01-02T15-04"))
-		log.Printf("heap profiling data will be saved in %s\n", n)
+	if query != (types.Query{}) {
+		result, _ = result.Query(query) // already validated by ParseQuery
+	}
 
-		heapProf, err := os.Create(n)
-		if err != nil {
-			log.Panicf("failed to create file [%s]: %v\n", n, err)
+	if err := encoder.Encode(os.Stdout, result); err != nil {
+		fatal(fmt.Errorf("failed to write result: %w", err))
+	}
+
+	if exp != nil {
+		exp.Time("encode", time.Since(start))
+		if err := exp.Stop(); err != nil {
+			log.Fatalf("failed to write profiling data: %v\n", err)
 		}
-		defer heapProf.Close()
+	}
+}
 
-		if err := pprof.WriteHeapProfile(heapProf); err != nil {
-			log.Panicf("failed to start cpu profiler: %v\n", err)
+// configFlags binds every processor knob in cfg to a flag in fs, using the
+// current values of cfg as defaults.
+func configFlags(fs *flag.FlagSet, cfg *processors.Config) {
+	fs.IntVar(&cfg.Processors, "processors", cfg.Processors, "number of worker goroutines parsing measurements")
+	fs.IntVar(&cfg.ProcessorChanSize, "processor-chan-size", cfg.ProcessorChanSize, "buffer size of the channel feeding the workers")
+	fs.IntVar(&cfg.AggregatorChanSize, "aggregator-chan-size", cfg.AggregatorChanSize, "buffer size of the channel feeding the aggregator")
+	fs.IntVar(&cfg.ChunkSize, "chunk-size", cfg.ChunkSize, fmt.Sprintf("bytes per sequential read (split-buf, local-global), %d when reading from stdin", processors.StreamChunkSize))
+	fs.IntVar(&cfg.ReadBuffers, "read-buffers", cfg.ReadBuffers, "number of chunk sized buffers reused while reading (split-buf)")
+	fs.IntVar(&cfg.ChunkCount, "chunk-count", cfg.ChunkCount, "number of chunks the file is split into (parallel-read)")
+	fs.IntVar(&cfg.ChunksChanSize, "chunks-chan-size", cfg.ChunksChanSize, "buffer size of the channel feeding the readers (parallel-read)")
+	fs.IntVar(&cfg.ReaderCount, "reader-count", cfg.ReaderCount, "number of reader goroutines (parallel-read)")
+	fs.IntVar(&cfg.LookAheadBytes, "look-ahead-bytes", cfg.LookAheadBytes, "bytes first read past a split point to find the next newline, doubled until one is found (parallel-read)")
+	fs.IntVar(&cfg.MaxLineLength, "max-line-length", cfg.MaxLineLength, "longest line in bytes the input may have (parallel-read)")
+	fs.BoolVar(&cfg.Mmap, "mmap", cfg.Mmap, "memory map the input instead of reading it (parallel-read, linux only)")
+	fs.IntVar(&cfg.SplitCount, "split-count", cfg.SplitCount, "number of pieces each read buffer is split into for the workers (parallel-read)")
+	fs.BoolVar(&cfg.Aggregates.Histogram, "histogram", cfg.Aggregates.Histogram, "keep an exact histogram per station for percentiles, percentile stats like p50 turn it on unless -sketch is set")
+	fs.BoolVar(&cfg.Aggregates.Sketch, "sketch", cfg.Aggregates.Sketch, fmt.Sprintf("keep a quantile sketch per station for percentiles that are within %g%% of the true ones", types.SketchRelativeAccuracy*100))
+}
+
+// stationFlags adds the flags that pick which stations a run aggregates to fs.
+// The returned function builds the filter once fs is parsed, it is nil when
+// none of the flags were set.
+func stationFlags(fs *flag.FlagSet) func() (*types.StationFilter, error) {
+	names := fs.String("stations", "", "comma separated station names to aggregate. Rows of other stations are skipped after their name is hashed and looked up, their measurement is never parsed")
+	file := fs.String("stations-file", "", "file with a station name per line to aggregate, on top of -stations")
+	prefixes := fs.String("stations-prefix", "", "comma separated prefixes of station names to aggregate")
+	expr := fs.String("stations-regexp", "", "regular expression matching station names to aggregate")
+
+	return func() (*types.StationFilter, error) {
+		stations := splitList(*names)
+		if *file != "" {
+			data, err := os.ReadFile(*file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read stations file: %w", err)
+			}
+
+			for _, line := range strings.Split(string(data), "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					stations = append(stations, line)
+				}
+			}
 		}
+
+		return types.NewStationFilter(stations, splitList(*prefixes), *expr)
 	}
 }
+
+// isFlagSet reports whether the flag called name was set on the command line.
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+
+	return set
+}
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -2,17 +2,78 @@ package types
 
 import (
 	"fmt"
+	"math"
+	"math/bits"
+	"slices"
 	"sort"
 	"strings"
 )
 
+// AgMeasures aggregates measurements in tenths of a degree. Every 1BRC
+// measurement has exactly one fractional digit so integers keep the sum exact
+// no matter how many rows there are, floats only show up at output time.
 type AgMeasures struct {
-	Min   float32
-	Max   float32
-	Total float64
+	Min int16
+	Max int16
+	Sum int64
+
+	// SumSq is the sum of the squared measurements in tenths², it is what
+	// Variance is computed from. At most 999² per row it doesn't overflow
+	// before 9e12 rows.
+	SumSq int64
 	Count int
 }
 
+// AgOptions picks the aggregates a Distribution keeps on top of the min,
+// max, sum, sum of squares and count of AgMeasures. They all cost memory and
+// time per row, so they are off unless asked for.
+type AgOptions struct {
+	// Histogram keeps an exact Histogram per station for percentiles.
+	Histogram bool
+
+	// Sketch keeps a Sketch per station for percentiles, which are then
+	// within SketchRelativeAccuracy of the true ones. Quantiles come from
+	// the histogram when both are kept. Only a sketch takes values outside
+	// of 1BRC measurements, see Distribution.AddFloat.
+	Sketch bool
+}
+
+// Any reports whether opts asks for any aggregate at all.
+func (opts AgOptions) Any() bool {
+	return opts.Histogram || opts.Sketch
+}
+
+// NewAgMeasures returns an AgMeasures ready for the first measurement.
+func NewAgMeasures() *AgMeasures {
+	return &AgMeasures{
+		Min: math.MaxInt16,
+		Max: math.MinInt16,
+	}
+}
+
+// Add adds a measurement of v tenths.
+func (m *AgMeasures) Add(v int16) {
+	m.Min = min(m.Min, v)
+	m.Max = max(m.Max, v)
+	m.Sum += int64(v)
+	m.SumSq += int64(v) * int64(v)
+	m.Count++
+}
+
+// Merge adds every measurement aggregated in o.
+func (m *AgMeasures) Merge(o *AgMeasures) {
+	m.Min = min(m.Min, o.Min)
+	m.Max = max(m.Max, o.Max)
+	m.Sum += o.Sum
+	m.SumSq += o.SumSq
+	m.Count += o.Count
+}
+
+// Equal reports whether m and o aggregated the same measurements.
+func (m *AgMeasures) Equal(o *AgMeasures) bool {
+	return m.Min == o.Min && m.Max == o.Max && m.Sum == o.Sum && m.SumSq == o.SumSq && m.Count == o.Count
+}
+
 type AgMeasureMap map[string]*AgMeasures
 
 func (ag AgMeasureMap) SortedString() string {
@@ -22,19 +83,188 @@ func (ag AgMeasureMap) SortedString() string {
 	}
 	sort.Strings(keys)
 
+	return ag.orderedString(keys)
+}
+
+// orderedString is SortedString with the stations in the order of keys.
+func (ag AgMeasureMap) orderedString(keys []string) string {
 	str := strings.Builder{}
 	str.WriteString("{")
 	for i, k := range keys {
 		v := ag[k]
 		if i == len(keys)-1 {
-			fmt.Fprintf(&str, "%s=%.1f/%.1f/%.1f", k, v.Min, v.Total/float64(v.Count), v.Max)
+			fmt.Fprintf(&str, "%s=%s/%s/%s", k, formatTenths(int64(v.Min)), formatTenths(v.MeanTenths()), formatTenths(int64(v.Max)))
 			continue
 		}
 
-		fmt.Fprintf(&str, "%s=%.1f/%.1f/%.1f, ", k, v.Min, v.Total/float64(v.Count), v.Max)
+		fmt.Fprintf(&str, "%s=%s/%s/%s, ", k, formatTenths(int64(v.Min)), formatTenths(v.MeanTenths()), formatTenths(int64(v.Max)))
 	}
 
 	str.WriteString("}")
-    
-    return str.String()
+
+	return str.String()
+}
+
+// Mean is the average of every measurement seen, in degrees.
+func (m *AgMeasures) Mean() float64 {
+	return float64(m.Sum) / 10 / float64(m.Count)
+}
+
+// MeanTenths is the average of every measurement seen rounded to tenths the
+// same way Round does, but without going through floats.
+func (m *AgMeasures) MeanTenths() int64 {
+	return floorDiv(2*m.Sum+int64(m.Count), 2*int64(m.Count))
+}
+
+// Variance is the population variance of the measurements, in degrees².
+//
+// Welford's update and Chan's parallel merge are there to stop float sums of
+// squares from cancelling out. Sum and SumSq are exact integers here, so
+// adding them up in Merge is all Chan's merge comes down to, and the one
+// subtraction left, n·SumSq - Sum², is done in 128 bits so it is exact too.
+func (m *AgMeasures) Variance() float64 {
+	if m.Count == 0 {
+		return 0
+	}
+
+	sum := uint64(m.Sum)
+	if m.Sum < 0 {
+		sum = uint64(-m.Sum)
+	}
+
+	n := uint64(m.Count)
+	hi, lo := bits.Mul64(n, uint64(m.SumSq))
+	sqHi, sqLo := bits.Mul64(sum, sum)
+	lo, borrow := bits.Sub64(lo, sqLo, 0)
+	hi, _ = bits.Sub64(hi, sqHi, borrow)
+
+	nm2 := float64(hi)*(1<<64) + float64(lo) // never negative, Cauchy-Schwarz
+	return nm2 / float64(n) / float64(n) / 100
+}
+
+// Stddev is the population standard deviation of the measurements, in
+// degrees.
+func (m *AgMeasures) Stddev() float64 {
+	return math.Sqrt(m.Variance())
+}
+
+// Result is what processors return. It keeps the raw numbers around so
+// callers don't have to parse SortedString back.
+type Result struct {
+	Measures AgMeasureMap
+
+	// Distributions holds the opt-in aggregates of every station when the
+	// run was asked for some with AgOptions, it is nil otherwise.
+	Distributions map[string]*Distribution
+
+	// Rows is the number of measurements aggregated and Bytes is the number
+	// of bytes read from the input.
+	Rows  int64
+	Bytes int64
+
+	// Files holds the result of every input file when a multi file run was
+	// asked for a per file breakdown, it is nil otherwise.
+	Files map[string]*Result
+
+	// Stats is where the processor spent its time, processors that don't
+	// keep track leave it nil.
+	Stats *Stats
+
+	// order is the station order Query picked, nil means by name.
+	order []string
+}
+
+// NewResult builds a Result out of the aggregated measures of an input of
+// size bytes.
+func NewResult(measures AgMeasureMap, bytes int64) *Result {
+	r := &Result{
+		Measures: measures,
+		Bytes:    bytes,
+	}
+
+	for _, m := range measures {
+		r.Rows += int64(m.Count)
+	}
+
+	return r
+}
+
+// Merge adds every measurement of o to r. The measures of o are copied, o
+// stays untouched.
+func (r *Result) Merge(o *Result) {
+	if r.Measures == nil {
+		r.Measures = AgMeasureMap{}
+	}
+
+	for k, v := range o.Measures {
+		m, ok := r.Measures[k]
+		if !ok {
+			m = NewAgMeasures()
+			r.Measures[k] = m
+		}
+		m.Merge(v)
+	}
+
+	for k, v := range o.Distributions {
+		if r.Distributions == nil {
+			r.Distributions = map[string]*Distribution{}
+		}
+
+		d, ok := r.Distributions[k]
+		if !ok {
+			d = &Distribution{}
+			r.Distributions[k] = d
+		}
+		d.Merge(v)
+	}
+
+	r.Rows += o.Rows
+	r.Bytes += o.Bytes
+	r.order = nil // stations of o might not be in it
+
+	if o.Stats != nil {
+		if r.Stats == nil {
+			r.Stats = &Stats{}
+		}
+		r.Stats.Merge(o.Stats)
+	}
+}
+
+// Stations returns the station names in the order Query put them in, sorted
+// by name if r didn't come from Query.
+func (r *Result) Stations() []string {
+	if r.order != nil {
+		return slices.Clone(r.order)
+	}
+
+	stations := make([]string, 0, len(r.Measures))
+	for k := range r.Measures {
+		stations = append(stations, k)
+	}
+	sort.Strings(stations)
+
+	return stations
+}
+
+// Get returns the measures of station, see Distribution for the opt-in
+// aggregates.
+func (r *Result) Get(station string) (*AgMeasures, bool) {
+	m, ok := r.Measures[station]
+	return m, ok
+}
+
+// Range calls fn for each station in the order of Stations until fn returns
+// false.
+func (r *Result) Range(fn func(station string, m *AgMeasures) bool) {
+	for _, k := range r.Stations() {
+		if !fn(k, r.Measures[k]) {
+			return
+		}
+	}
+}
+
+// String returns the result in the 1BRC output format, with the stations in
+// the order of Stations.
+func (r *Result) String() string {
+	return r.Measures.orderedString(r.Stations())
 }
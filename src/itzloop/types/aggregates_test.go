@@ -0,0 +1,140 @@
+package types
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResult(t *testing.T) {
+	r := NewResult(AgMeasureMap{
+		"Istanbul": {Min: 62, Max: 230, Sum: 292, Count: 2},
+		"Hamburg":  {Min: 120, Max: 120, Sum: 120, Count: 1},
+		"Abha":     {Min: -230, Max: 592, Sum: 362, Count: 3},
+	}, 88)
+
+	assert.EqualValues(t, 6, r.Rows)
+	assert.EqualValues(t, 88, r.Bytes)
+	assert.Equal(t, []string{"Abha", "Hamburg", "Istanbul"}, r.Stations())
+
+	m, ok := r.Get("Istanbul")
+	require.True(t, ok)
+	assert.InDelta(t, 14.6, m.Mean(), 1e-9)
+
+	_, ok = r.Get("Oslo")
+	assert.False(t, ok)
+
+	var visited []string
+	r.Range(func(station string, m *AgMeasures) bool {
+		visited = append(visited, station)
+		return len(visited) < 2
+	})
+	assert.Equal(t, []string{"Abha", "Hamburg"}, visited)
+
+	assert.Equal(t, "{Abha=-23.0/12.1/59.2, Hamburg=12.0/12.0/12.0, Istanbul=6.2/14.6/23.0}", r.String())
+}
+
+func TestResultMerge(t *testing.T) {
+	r := NewResult(AgMeasureMap{
+		"Hamburg": {Min: 120, Max: 120, Sum: 120, Count: 1},
+	}, 13)
+	o := NewResult(AgMeasureMap{
+		"Hamburg": {Min: -10, Max: 50, Sum: 40, Count: 2},
+		"Abha":    {Min: 3, Max: 3, Sum: 3, Count: 1},
+	}, 30)
+
+	r.Merge(o)
+
+	assert.EqualValues(t, 4, r.Rows)
+	assert.EqualValues(t, 43, r.Bytes)
+	assert.Equal(t, "{Abha=0.3/0.3/0.3, Hamburg=-1.0/5.3/12.0}", r.String())
+
+	// o must not share its measures with r
+	assert.Equal(t, "{Abha=0.3/0.3/0.3, Hamburg=-1.0/2.0/5.0}", o.String())
+	r.Measures["Abha"].Add(100)
+	assert.EqualValues(t, 1, o.Measures["Abha"].Count)
+
+	t.Run("distributions", func(t *testing.T) {
+		d := NewDistribution(AgOptions{Histogram: true})
+		d.Add(3)
+		o.Distributions = map[string]*Distribution{"Abha": d}
+
+		r := NewResult(AgMeasureMap{}, 0)
+		r.Merge(o)
+		r.Merge(o)
+		require.Contains(t, r.Distributions, "Abha")
+		assert.NotSame(t, d, r.Distributions["Abha"])
+
+		p50, ok := r.Distributions["Abha"].Quantile(0.5, 2)
+		require.True(t, ok)
+		assert.Equal(t, 0.3, p50)
+		assert.EqualValues(t, 1, d.Hist.Count(3))
+	})
+}
+
+func TestResultMergeStats(t *testing.T) {
+	r := NewResult(AgMeasureMap{}, 0)
+	o := NewResult(AgMeasureMap{}, 0)
+	o.Stats = &Stats{
+		Wall:              time.Second,
+		AggregatorBacklog: 2,
+		Readers:           []ReaderStats{{Bytes: 10, Reads: 1, Read: time.Millisecond}},
+		Workers:           []WorkerStats{{Bytes: 4, Rows: 1, Blocks: 1, Parse: time.Millisecond}, {Bytes: 6, Rows: 2, Blocks: 1, Parse: 2 * time.Millisecond}},
+	}
+
+	r.Merge(o)
+	r.Merge(o)
+
+	require.NotNil(t, r.Stats)
+	assert.Equal(t, 2*time.Second, r.Stats.Wall)
+	assert.Equal(t, 2, r.Stats.AggregatorBacklog)
+	assert.Equal(t, []ReaderStats{{Bytes: 20, Reads: 2, Read: 2 * time.Millisecond}}, r.Stats.Readers)
+	assert.Equal(t, WorkerStats{Bytes: 12, Rows: 4, Blocks: 2, Parse: 4 * time.Millisecond}, r.Stats.Workers[1])
+	assert.Equal(t, 2*time.Millisecond, r.Stats.Read)
+	assert.Equal(t, 6*time.Millisecond, r.Stats.Parse)
+
+	// o must not share its readers and workers with r
+	assert.EqualValues(t, 10, o.Stats.Readers[0].Bytes)
+}
+
+func TestVariance(t *testing.T) {
+	measures := func(vs ...int16) *AgMeasures {
+		m := NewAgMeasures()
+		for _, v := range vs {
+			m.Add(v)
+		}
+		return m
+	}
+
+	// 1.0, 2.0, 3.0 and 4.0 degrees
+	m := measures(10, 20, 30, 40)
+	assert.InDelta(t, 1.25, m.Variance(), 1e-12)
+	assert.InDelta(t, math.Sqrt(1.25), m.Stddev(), 1e-12)
+
+	assert.Zero(t, measures(-123).Variance())
+	assert.Zero(t, measures(-999, -999, -999).Variance())
+	assert.Zero(t, NewAgMeasures().Variance())
+
+	t.Run("merge", func(t *testing.T) {
+		a, b := measures(-999, 12, 500), measures(999, -1, -1, 37)
+		a.Merge(b)
+		assert.Equal(t, measures(-999, 12, 500, 999, -1, -1, 37), a)
+	})
+
+	t.Run("large counts", func(t *testing.T) {
+		// a mean far from zero and a tiny spread is where sums of squares in
+		// floats fall apart.
+		m := NewAgMeasures()
+		for i := 0; i < 1_000_000; i++ {
+			m.Add(int16(998 + i%2))
+		}
+		assert.InDelta(t, 0.0025, m.Variance(), 1e-15)
+
+		// a billion rows of ±99.9 doesn't fit the product in 64 bits
+		big := &AgMeasures{Min: -999, Max: 999, Sum: 0, SumSq: 1e9 * 999 * 999, Count: 1e9}
+		assert.InDelta(t, 9980.01, big.Variance(), 1e-9)
+	})
+}
@@ -0,0 +1,105 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// columns are the per station statistics encoders can write, each formatted
+// the way it shows up in the output. Statistics a station can't tell are
+// empty, like percentiles without a Distribution.
+var columns = map[string]func(m *AgMeasures, d *Distribution) string{
+	"min":      func(m *AgMeasures, _ *Distribution) string { return formatTenths(int64(m.Min)) },
+	"mean":     func(m *AgMeasures, _ *Distribution) string { return formatTenths(m.MeanTenths()) },
+	"max":      func(m *AgMeasures, _ *Distribution) string { return formatTenths(int64(m.Max)) },
+	"count":    func(m *AgMeasures, _ *Distribution) string { return strconv.Itoa(m.Count) },
+	"stddev":   func(m *AgMeasures, _ *Distribution) string { return formatMeasure(m.Stddev()) },
+	"variance": func(m *AgMeasures, _ *Distribution) string { return formatMeasure(m.Variance()) },
+	"p50":      quantileColumn(0.5),
+	"p90":      quantileColumn(0.9),
+	"p99":      quantileColumn(0.99),
+}
+
+func quantileColumn(q float64) func(m *AgMeasures, d *Distribution) string {
+	return func(m *AgMeasures, d *Distribution) string {
+		v, ok := d.Quantile(q, m.Count)
+		if !ok {
+			return ""
+		}
+
+		return formatMeasure(v)
+	}
+}
+
+// column returns the column called name. Other percentiles than the ones in
+// columns are spelled the same way, p99.9 or p25.
+func column(name string) (func(m *AgMeasures, d *Distribution) string, bool) {
+	if c, ok := columns[name]; ok {
+		return c, true
+	}
+
+	p, ok := strings.CutPrefix(name, "p")
+	if !ok {
+		return nil, false
+	}
+
+	q, err := strconv.ParseFloat(p, 64)
+	if err != nil || !(q > 0 && q <= 100) {
+		return nil, false
+	}
+
+	return quantileColumn(q / 100), true
+}
+
+// QuantileColumns reports whether any of names is a percentile. Those need a
+// Distribution quantiles can be read from, see AgOptions.
+func QuantileColumns(names []string) bool {
+	for _, name := range names {
+		if strings.HasPrefix(name, "p") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Columns returns the names of the statistics encoders can write in sorted
+// order.
+func Columns() []string {
+	names := make([]string, 0, len(columns))
+	for k := range columns {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ParseColumns splits a comma separated list of column names, like
+// min,mean,max,stddev,count, and checks that every one of them exists.
+func ParseColumns(s string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := column(name); !ok {
+			return nil, fmt.Errorf("unknown stat %q, available stats are: %s", name, strings.Join(Columns(), ", "))
+		}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// formatColumns formats the columns of station in r in order.
+func formatColumns(r *Result, station string, names []string) []string {
+	m, d := r.Measures[station], r.Distributions[station]
+	values := make([]string, len(names))
+	for i, name := range names {
+		c, _ := column(name)
+		values[i] = c(m, d)
+	}
+
+	return values
+}
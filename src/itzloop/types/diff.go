@@ -0,0 +1,70 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Diff is a station whose measures are not the same in two results. Want or
+// Got is nil when the station is missing from that result.
+type Diff struct {
+	Station string
+	Want    *AgMeasures
+	Got     *AgMeasures
+
+	// Distribution is set when only the distributions of the station
+	// differ, see Distribution.
+	Distribution bool
+}
+
+func (d Diff) String() string {
+	switch {
+	case d.Got == nil:
+		return fmt.Sprintf("%s: missing, want %s", d.Station, describeMeasures(d.Want))
+	case d.Want == nil:
+		return fmt.Sprintf("%s: unexpected %s", d.Station, describeMeasures(d.Got))
+	case d.Distribution:
+		return fmt.Sprintf("%s: %s, but the distributions differ", d.Station, describeMeasures(d.Got))
+	}
+
+	return fmt.Sprintf("%s: got %s, want %s", d.Station, describeMeasures(d.Got), describeMeasures(d.Want))
+}
+
+func describeMeasures(m *AgMeasures) string {
+	return fmt.Sprintf("min=%s mean=%s max=%s sum=%s count=%d", formatTenths(int64(m.Min)), formatTenths(m.MeanTenths()), formatTenths(int64(m.Max)), formatTenths(m.Sum), m.Count)
+}
+
+// Compare returns every station of want and got whose measures differ,
+// sorted by name. The raw aggregates are compared, distributions included,
+// two results can differ even when their rounded output is the same.
+func Compare(want, got *Result) []Diff {
+	var diffs []Diff
+	for station, w := range want.Measures {
+		g, ok := got.Measures[station]
+		if !ok {
+			diffs = append(diffs, Diff{Station: station, Want: w})
+			continue
+		}
+
+		if !w.Equal(g) {
+			diffs = append(diffs, Diff{Station: station, Want: w, Got: g})
+			continue
+		}
+
+		if !want.Distributions[station].Equal(got.Distributions[station]) {
+			diffs = append(diffs, Diff{Station: station, Want: w, Got: g, Distribution: true})
+		}
+	}
+
+	for station, g := range got.Measures {
+		if _, ok := want.Measures[station]; !ok {
+			diffs = append(diffs, Diff{Station: station, Got: g})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Station < diffs[j].Station
+	})
+
+	return diffs
+}
@@ -0,0 +1,56 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompare(t *testing.T) {
+	want := NewResult(AgMeasureMap{
+		"Hamburg":  {Min: 120, Max: 120, Sum: 120, Count: 1},
+		"Istanbul": {Min: 62, Max: 230, Sum: 292, Count: 2},
+		"Abha":     {Min: -230, Max: 592, Sum: 362, Count: 3},
+	}, 88)
+
+	t.Run("same", func(t *testing.T) {
+		got := NewResult(AgMeasureMap{}, 0)
+		got.Merge(want)
+		assert.Empty(t, Compare(want, got))
+	})
+
+	t.Run("different", func(t *testing.T) {
+		got := NewResult(AgMeasureMap{
+			"Istanbul": {Min: 62, Max: 230, Sum: 293, Count: 2},
+			"Abha":     {Min: -230, Max: 592, Sum: 362, Count: 3},
+			"Oslo":     {Min: 10, Max: 10, Sum: 10, Count: 1},
+		}, 88)
+
+		diffs := Compare(want, got)
+		require.Len(t, diffs, 3)
+
+		assert.Equal(t, "Hamburg: missing, want min=12.0 mean=12.0 max=12.0 sum=12.0 count=1", diffs[0].String())
+		assert.Equal(t, "Istanbul: got min=6.2 mean=14.7 max=23.0 sum=29.3 count=2, want min=6.2 mean=14.6 max=23.0 sum=29.2 count=2", diffs[1].String())
+		assert.Equal(t, "Oslo: unexpected min=1.0 mean=1.0 max=1.0 sum=1.0 count=1", diffs[2].String())
+	})
+
+	t.Run("distributions", func(t *testing.T) {
+		withDists := func(v int16) *Result {
+			r := NewResult(AgMeasureMap{}, 0)
+			r.Merge(want)
+			d := NewDistribution(AgOptions{Histogram: true})
+			d.Add(v)
+			r.Distributions = map[string]*Distribution{"Hamburg": d}
+			return r
+		}
+
+		assert.Empty(t, Compare(withDists(120), withDists(120)))
+
+		diffs := Compare(withDists(120), withDists(121))
+		require.Len(t, diffs, 1)
+		assert.Equal(t, "Hamburg: min=12.0 mean=12.0 max=12.0 sum=12.0 count=1, but the distributions differ", diffs[0].String())
+
+		assert.Len(t, Compare(want, withDists(120)), 1)
+	})
+}
@@ -0,0 +1,103 @@
+package types
+
+// Distribution holds the opt-in aggregates of a station, the ones AgOptions
+// picks. They are kept apart from AgMeasures so that runs that don't ask for
+// them don't pay for them, neither in the size of every AgMeasures nor in
+// every Add.
+type Distribution struct {
+	Hist   *Histogram
+	Sketch *Sketch
+}
+
+// NewDistribution returns an empty Distribution keeping what opts asks for,
+// nil if it asks for nothing.
+func NewDistribution(opts AgOptions) *Distribution {
+	if !opts.Any() {
+		return nil
+	}
+
+	d := &Distribution{}
+	if opts.Histogram {
+		d.Hist = &Histogram{}
+	}
+	if opts.Sketch {
+		d.Sketch = NewSketch()
+	}
+
+	return d
+}
+
+// Add adds a measurement of v tenths.
+func (d *Distribution) Add(v int16) {
+	if d.Hist != nil {
+		d.Hist.Add(v)
+	}
+	if d.Sketch != nil {
+		d.Sketch.Add(float64(v) / 10)
+	}
+}
+
+// AddFloat adds a value of any range and precision, for data that isn't
+// bounded to 1BRC measurements. Only a sketch keeps those, so it panics if d
+// keeps a histogram, see NewDistribution with AgOptions{Sketch: true}. Pass
+// Sketch.Count as the count to Quantile, AgMeasures doesn't see these values.
+func (d *Distribution) AddFloat(v float64) {
+	if d.Hist != nil {
+		panic("types: AddFloat on a Distribution with a histogram")
+	}
+	d.Sketch.Add(v)
+}
+
+// Merge adds every measurement of o to d. Aggregates o keeps and d doesn't
+// are copied over.
+func (d *Distribution) Merge(o *Distribution) {
+	if o.Hist != nil {
+		if d.Hist == nil {
+			d.Hist = &Histogram{}
+		}
+		d.Hist.Merge(o.Hist)
+	}
+
+	if o.Sketch != nil {
+		if d.Sketch == nil {
+			d.Sketch = NewSketch()
+		}
+		d.Sketch.Merge(o.Sketch)
+	}
+}
+
+// Equal reports whether d and o keep the same aggregates of the same
+// measurements. Nil is only equal to nil.
+func (d *Distribution) Equal(o *Distribution) bool {
+	if d == nil || o == nil {
+		return d == o
+	}
+
+	if (d.Hist == nil) != (o.Hist == nil) || (d.Sketch == nil) != (o.Sketch == nil) {
+		return false
+	}
+
+	if d.Hist != nil && !d.Hist.Equal(o.Hist) {
+		return false
+	}
+
+	return d.Sketch == nil || d.Sketch.Equal(o.Sketch)
+}
+
+// Quantile returns the measurement at quantile q, 0 < q <= 1, of the count
+// measurements in d, in degrees. It comes from the histogram when d keeps
+// both. It is false when d is nil or empty.
+func (d *Distribution) Quantile(q float64, count int) (float64, bool) {
+	if d == nil || count == 0 {
+		return 0, false
+	}
+
+	switch {
+	case d.Hist != nil:
+		return float64(d.Hist.Quantile(q, count)) / 10, true
+	case d.Sketch != nil:
+		return d.Sketch.Quantile(q), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,309 @@
+package types
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Encoder writes a Result to w in some output format.
+type Encoder interface {
+	Encode(w io.Writer, r *Result) error
+}
+
+var encoders = map[string]func(cols []string) Encoder{
+	"1brc":   func(cols []string) Encoder { return CanonicalEncoder{Columns: cols} },
+	"json":   func(cols []string) Encoder { return JSONEncoder{Columns: cols} },
+	"ndjson": func(cols []string) Encoder { return NDJSONEncoder{Columns: cols} },
+	"csv":    func(cols []string) Encoder { return CSVEncoder{Comma: ',', Columns: cols} },
+	"tsv":    func(cols []string) Encoder { return CSVEncoder{Comma: '\t', Columns: cols} },
+}
+
+// NewEncoder returns the encoder for format, which is one of Formats. cols
+// picks the statistics written for every station out of Columns, without any
+// the usual columns of the format are written.
+func NewEncoder(format string, cols ...string) (Encoder, error) {
+	newEnc, ok := encoders[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format %q, available formats are: %s", format, strings.Join(Formats(), ", "))
+	}
+
+	for _, c := range cols {
+		if _, ok := column(c); !ok {
+			return nil, fmt.Errorf("unknown stat %q, available stats are: %s", c, strings.Join(Columns(), ", "))
+		}
+	}
+
+	return newEnc(cols), nil
+}
+
+// Formats returns the names of the available output formats in sorted order.
+func Formats() []string {
+	formats := make([]string, 0, len(encoders))
+	for k := range encoders {
+		formats = append(formats, k)
+	}
+	sort.Strings(formats)
+
+	return formats
+}
+
+var (
+	canonicalColumns = []string{"min", "mean", "max"}
+	recordColumns    = []string{"min", "mean", "max", "count"}
+)
+
+// TotalName is what the merged result of a per file breakdown is called in
+// the output, next to the names of the files.
+const TotalName = "total"
+
+// perFile returns the names and results of the files of r in sorted order,
+// followed by r itself as TotalName. Without a per file breakdown it is just r
+// with an empty name.
+func perFile(r *Result) ([]string, []*Result) {
+	if r.Files == nil {
+		return []string{""}, []*Result{r}
+	}
+
+	names := make([]string, 0, len(r.Files)+1)
+	for k := range r.Files {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	results := make([]*Result, 0, len(names)+1)
+	for _, k := range names {
+		results = append(results, r.Files[k])
+	}
+
+	return append(names, TotalName), append(results, r)
+}
+
+// CanonicalEncoder writes the 1BRC output, {Abha=-23.0/18.0/59.2, ...}. With
+// Columns set every station has those values separated by slashes instead. A
+// per file breakdown is written as a ==> file <== line followed by the result
+// of the file, with ==> total <== last.
+type CanonicalEncoder struct {
+	Columns []string
+}
+
+func (ce CanonicalEncoder) Encode(w io.Writer, r *Result) error {
+	cols := ce.Columns
+	if len(cols) == 0 {
+		cols = canonicalColumns
+	}
+
+	bw := bufio.NewWriter(w)
+	names, results := perFile(r)
+	for i, fr := range results {
+		if names[i] != "" {
+			fmt.Fprintf(bw, "==> %s <==\n", names[i])
+		}
+
+		bw.WriteString("{")
+		for j, k := range fr.Stations() {
+			if j > 0 {
+				bw.WriteString(", ")
+			}
+
+			bw.WriteString(k)
+			bw.WriteString("=")
+			bw.WriteString(strings.Join(formatColumns(fr, k, cols), "/"))
+		}
+		bw.WriteString("}\n")
+	}
+
+	return bw.Flush()
+}
+
+// appendRecord appends how a single station looks in JSON and NDJSON output
+// to buf, values being the formatted cols. The file and station names are
+// left out when empty and statistics the station can't tell are null.
+func appendRecord(buf []byte, file, station string, values []string, cols []string) ([]byte, error) {
+	buf = append(buf, '{')
+	for _, f := range [...]struct{ key, value string }{{"file", file}, {"station", station}} {
+		if f.value == "" {
+			continue
+		}
+
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+
+		buf = strconv.AppendQuote(buf, f.key)
+		buf = append(buf, ':')
+		buf = append(buf, value...)
+		buf = append(buf, ',')
+	}
+
+	for i, v := range values {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+
+		buf = strconv.AppendQuote(buf, cols[i])
+		buf = append(buf, ':')
+		if v == "" {
+			v = "null"
+		}
+		buf = append(buf, v...)
+	}
+
+	return append(buf, '}'), nil
+}
+
+// JSONEncoder writes a single JSON object keyed by station name, in sorted
+// order. A per file breakdown is an object keyed by file name, plus TotalName
+// for the merged result, of those station objects.
+type JSONEncoder struct {
+	Columns []string
+}
+
+func (je JSONEncoder) Encode(w io.Writer, r *Result) error {
+	cols := je.Columns
+	if len(cols) == 0 {
+		cols = recordColumns
+	}
+
+	bw := bufio.NewWriter(w)
+	names, results := perFile(r)
+	if r.Files != nil {
+		bw.WriteString("{")
+	}
+	for i, fr := range results {
+		if names[i] != "" {
+			if i > 0 {
+				bw.WriteString(",")
+			}
+
+			key, err := json.Marshal(names[i])
+			if err != nil {
+				return err
+			}
+			bw.Write(key)
+			bw.WriteString(":")
+		}
+
+		if err := writeJSONStations(bw, fr, cols); err != nil {
+			return err
+		}
+	}
+	if r.Files != nil {
+		bw.WriteString("}")
+	}
+	bw.WriteString("\n")
+
+	return bw.Flush()
+}
+
+func writeJSONStations(bw *bufio.Writer, r *Result, cols []string) error {
+	bw.WriteString("{")
+	var buf []byte
+	for i, k := range r.Stations() {
+		if i > 0 {
+			bw.WriteString(",")
+		}
+
+		key, err := json.Marshal(k)
+		if err != nil {
+			return err
+		}
+
+		buf, err = appendRecord(buf[:0], "", "", formatColumns(r, k, cols), cols)
+		if err != nil {
+			return err
+		}
+
+		bw.Write(key)
+		bw.WriteString(":")
+		bw.Write(buf)
+	}
+	bw.WriteString("}")
+
+	return nil
+}
+
+// NDJSONEncoder writes one JSON object per station per line. With a per file
+// breakdown every object has a file field, TotalName for the merged result.
+type NDJSONEncoder struct {
+	Columns []string
+}
+
+func (ne NDJSONEncoder) Encode(w io.Writer, r *Result) error {
+	cols := ne.Columns
+	if len(cols) == 0 {
+		cols = recordColumns
+	}
+
+	bw := bufio.NewWriter(w)
+	var (
+		buf []byte
+		err error
+	)
+	names, results := perFile(r)
+	for i, fr := range results {
+		for _, k := range fr.Stations() {
+			buf, err = appendRecord(buf[:0], names[i], k, formatColumns(fr, k, cols), cols)
+			if err != nil {
+				return err
+			}
+
+			bw.Write(buf)
+			bw.WriteString("\n")
+		}
+	}
+
+	return bw.Flush()
+}
+
+// CSVEncoder writes a header of station,min,mean,max,count, or station and
+// Columns when set, followed by one record per station. Comma is the field
+// delimiter, ',' for CSV and '\t' for TSV. With a per file breakdown every
+// record starts with a file column, TotalName for the merged result.
+type CSVEncoder struct {
+	Comma   rune
+	Columns []string
+}
+
+func (ce CSVEncoder) Encode(w io.Writer, r *Result) error {
+	cols := ce.Columns
+	if len(cols) == 0 {
+		cols = recordColumns
+	}
+
+	cw := csv.NewWriter(w)
+	if ce.Comma != 0 {
+		cw.Comma = ce.Comma
+	}
+
+	header := append([]string{"station"}, cols...)
+	if r.Files != nil {
+		header = append([]string{"file"}, header...)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	names, results := perFile(r)
+	for i, fr := range results {
+		for _, k := range fr.Stations() {
+			record := append([]string{k}, formatColumns(fr, k, cols)...)
+			if names[i] != "" {
+				record = append([]string{names[i]}, record...)
+			}
+
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,216 @@
+package types
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncoders(t *testing.T) {
+	r := NewResult(AgMeasureMap{
+		"Istanbul":   {Min: 62, Max: 230, Sum: 292, Count: 2},
+		"St. John's": {Min: 152, Max: 152, Sum: 152, Count: 1},
+	}, 0)
+
+	table := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format:   "1brc",
+			expected: "{Istanbul=6.2/14.6/23.0, St. John's=15.2/15.2/15.2}\n",
+		},
+		{
+			format:   "json",
+			expected: `{"Istanbul":{"min":6.2,"mean":14.6,"max":23.0,"count":2},"St. John's":{"min":15.2,"mean":15.2,"max":15.2,"count":1}}` + "\n",
+		},
+		{
+			format: "ndjson",
+			expected: `{"station":"Istanbul","min":6.2,"mean":14.6,"max":23.0,"count":2}` + "\n" +
+				`{"station":"St. John's","min":15.2,"mean":15.2,"max":15.2,"count":1}` + "\n",
+		},
+		{
+			format:   "csv",
+			expected: "station,min,mean,max,count\nIstanbul,6.2,14.6,23.0,2\nSt. John's,15.2,15.2,15.2,1\n",
+		},
+		{
+			format:   "tsv",
+			expected: "station\tmin\tmean\tmax\tcount\nIstanbul\t6.2\t14.6\t23.0\t2\nSt. John's\t15.2\t15.2\t15.2\t1\n",
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.format, func(t *testing.T) {
+			enc, err := NewEncoder(tc.format)
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			require.NoError(t, enc.Encode(&buf, r))
+			assert.Equal(t, tc.expected, buf.String())
+		})
+	}
+
+	_, err := NewEncoder("xml")
+	assert.Error(t, err)
+}
+
+// TestEncodersPerFile checks that a per file breakdown is still a single
+// document consumers can parse.
+func TestEncodersPerFile(t *testing.T) {
+	a := NewResult(AgMeasureMap{"Istanbul": {Min: 62, Max: 230, Sum: 292, Count: 2}}, 0)
+	b := NewResult(AgMeasureMap{"St. John's": {Min: 152, Max: 152, Sum: 152, Count: 1}}, 0)
+
+	r := &Result{}
+	r.Merge(a)
+	r.Merge(b)
+	r.Files = map[string]*Result{"b.txt": b, "a.txt": a}
+
+	encode := func(t *testing.T, format string) []byte {
+		enc, err := NewEncoder(format)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, enc.Encode(&buf, r))
+		return buf.Bytes()
+	}
+
+	t.Run("1brc", func(t *testing.T) {
+		assert.Equal(t, "==> a.txt <==\n{Istanbul=6.2/14.6/23.0}\n"+
+			"==> b.txt <==\n{St. John's=15.2/15.2/15.2}\n"+
+			"==> total <==\n{Istanbul=6.2/14.6/23.0, St. John's=15.2/15.2/15.2}\n", string(encode(t, "1brc")))
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var got map[string]map[string]map[string]any
+		require.NoError(t, json.Unmarshal(encode(t, "json"), &got))
+		assert.Equal(t, map[string]map[string]map[string]any{
+			"a.txt": {"Istanbul": {"min": 6.2, "mean": 14.6, "max": 23.0, "count": 2.0}},
+			"b.txt": {"St. John's": {"min": 15.2, "mean": 15.2, "max": 15.2, "count": 1.0}},
+			"total": {
+				"Istanbul":   {"min": 6.2, "mean": 14.6, "max": 23.0, "count": 2.0},
+				"St. John's": {"min": 15.2, "mean": 15.2, "max": 15.2, "count": 1.0},
+			},
+		}, got)
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		dec := json.NewDecoder(bytes.NewReader(encode(t, "ndjson")))
+		var files, stations []string
+		for dec.More() {
+			var record struct{ File, Station string }
+			require.NoError(t, dec.Decode(&record))
+			files = append(files, record.File)
+			stations = append(stations, record.Station)
+		}
+		assert.Equal(t, []string{"a.txt", "b.txt", "total", "total"}, files)
+		assert.Equal(t, []string{"Istanbul", "St. John's", "Istanbul", "St. John's"}, stations)
+	})
+
+	for format, comma := range map[string]rune{"csv": ',', "tsv": '\t'} {
+		t.Run(format, func(t *testing.T) {
+			cr := csv.NewReader(bytes.NewReader(encode(t, format)))
+			cr.Comma = comma
+			records, err := cr.ReadAll()
+			require.NoError(t, err)
+			assert.Equal(t, [][]string{
+				{"file", "station", "min", "mean", "max", "count"},
+				{"a.txt", "Istanbul", "6.2", "14.6", "23.0", "2"},
+				{"b.txt", "St. John's", "15.2", "15.2", "15.2", "1"},
+				{"total", "Istanbul", "6.2", "14.6", "23.0", "2"},
+				{"total", "St. John's", "15.2", "15.2", "15.2", "1"},
+			}, records)
+		})
+	}
+}
+
+func TestEncoderColumns(t *testing.T) {
+	istanbul := NewAgMeasures()
+	for _, v := range []int16{62, 230, 100} {
+		istanbul.Add(v)
+	}
+	r := NewResult(AgMeasureMap{"Istanbul": istanbul}, 0)
+	cols := []string{"mean", "stddev", "variance", "count"}
+
+	table := []struct {
+		format   string
+		expected string
+	}{
+		{format: "1brc", expected: "{Istanbul=13.1/7.2/51.7/3}\n"},
+		{format: "json", expected: `{"Istanbul":{"mean":13.1,"stddev":7.2,"variance":51.7,"count":3}}` + "\n"},
+		{format: "ndjson", expected: `{"station":"Istanbul","mean":13.1,"stddev":7.2,"variance":51.7,"count":3}` + "\n"},
+		{format: "csv", expected: "station,mean,stddev,variance,count\nIstanbul,13.1,7.2,51.7,3\n"},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.format, func(t *testing.T) {
+			enc, err := NewEncoder(tc.format, cols...)
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			require.NoError(t, enc.Encode(&buf, r))
+			assert.Equal(t, tc.expected, buf.String())
+		})
+	}
+
+	_, err := NewEncoder("csv", "median")
+	assert.Error(t, err)
+}
+
+func TestEncoderQuantileColumns(t *testing.T) {
+	m, d := NewAgMeasures(), NewDistribution(AgOptions{Histogram: true})
+	for _, v := range []int16{62, 230, 100, -5} {
+		m.Add(v)
+		d.Add(v)
+	}
+	cols := []string{"p50", "p90", "p99.9"}
+
+	table := []struct {
+		format   string
+		d        *Distribution
+		expected string
+	}{
+		{format: "1brc", d: d, expected: "{Istanbul=6.2/23.0/23.0}\n"},
+		{format: "csv", expected: "station,p50,p90,p99.9\nIstanbul,,,\n"},
+		{format: "ndjson", expected: `{"station":"Istanbul","p50":null,"p90":null,"p99.9":null}` + "\n"},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.format, func(t *testing.T) {
+			enc, err := NewEncoder(tc.format, cols...)
+			require.NoError(t, err)
+
+			r := NewResult(AgMeasureMap{"Istanbul": m}, 0)
+			if tc.d != nil {
+				r.Distributions = map[string]*Distribution{"Istanbul": tc.d}
+			}
+
+			var buf bytes.Buffer
+			require.NoError(t, enc.Encode(&buf, r))
+			assert.Equal(t, tc.expected, buf.String())
+		})
+	}
+
+	assert.True(t, QuantileColumns(cols))
+	assert.False(t, QuantileColumns([]string{"min", "stddev"}))
+
+	for _, bad := range []string{"p0", "p101", "pp"} {
+		_, err := NewEncoder("csv", bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	cols, err := ParseColumns("min,mean, max,stddev,count,p50,p99.9")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"min", "mean", "max", "stddev", "count", "p50", "p99.9"}, cols)
+
+	_, err = ParseColumns("min,,max")
+	assert.Error(t, err)
+
+	_, err = ParseColumns("median")
+	assert.Error(t, err)
+}
@@ -0,0 +1,63 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+)
+
+// StationFilter picks the stations a run aggregates, by exact name, name
+// prefix or regular expression. A station is picked if it matches any of
+// them. A nil *StationFilter picks every station.
+type StationFilter struct {
+	names    map[string]struct{}
+	prefixes [][]byte
+	re       *regexp.Regexp
+}
+
+// NewStationFilter returns a filter for the given names, prefixes and
+// regular expression, any of which can be empty. It returns nil, which picks
+// every station, when all of them are.
+func NewStationFilter(names, prefixes []string, expr string) (*StationFilter, error) {
+	if len(names) == 0 && len(prefixes) == 0 && expr == "" {
+		return nil, nil
+	}
+
+	f := &StationFilter{names: make(map[string]struct{}, len(names))}
+	for _, n := range names {
+		f.names[n] = struct{}{}
+	}
+	for _, p := range prefixes {
+		f.prefixes = append(f.prefixes, []byte(p))
+	}
+
+	if expr != "" {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid station regexp: %w", err)
+		}
+		f.re = re
+	}
+
+	return f, nil
+}
+
+// Match reports whether the station called name is picked. It is meant to be
+// called once per station, not once per row, see utils.CustomMap.Select.
+func (f *StationFilter) Match(name []byte) bool {
+	if f == nil {
+		return true
+	}
+
+	if _, ok := f.names[string(name)]; ok {
+		return true
+	}
+
+	for _, p := range f.prefixes {
+		if bytes.HasPrefix(name, p) {
+			return true
+		}
+	}
+
+	return f.re != nil && f.re.Match(name)
+}
@@ -0,0 +1,42 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStationFilter(t *testing.T) {
+	table := []struct {
+		name     string
+		names    []string
+		prefixes []string
+		expr     string
+		match    []string
+		noMatch  []string
+	}{
+		{name: "everything", match: []string{"Hamburg", ""}},
+		{name: "names", names: []string{"Hamburg", "Istanbul"}, match: []string{"Hamburg", "Istanbul"}, noMatch: []string{"Ham", "Hamburgg", "hamburg"}},
+		{name: "prefixes", prefixes: []string{"San ", "St."}, match: []string{"San Jose", "St. John's"}, noMatch: []string{"Santiago", "Oslo"}},
+		{name: "regexp", expr: `^(Abha|Oslo)$|ü`, match: []string{"Abha", "Zürich"}, noMatch: []string{"Abhab", "Zurich"}},
+		{name: "any of them", names: []string{"Oslo"}, prefixes: []string{"Ha"}, expr: `ul$`, match: []string{"Oslo", "Hanoi", "Istanbul"}, noMatch: []string{"Abha"}},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := NewStationFilter(tc.names, tc.prefixes, tc.expr)
+			require.NoError(t, err)
+
+			for _, s := range tc.match {
+				assert.True(t, f.Match([]byte(s)), s)
+			}
+			for _, s := range tc.noMatch {
+				assert.False(t, f.Match([]byte(s)), s)
+			}
+		})
+	}
+
+	_, err := NewStationFilter(nil, nil, "(")
+	assert.Error(t, err)
+}
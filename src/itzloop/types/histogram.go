@@ -0,0 +1,97 @@
+package types
+
+import "math"
+
+// HistogramBuckets is one bucket for every tenth from -99.9 to 99.9.
+const HistogramBuckets = 1999
+
+// Histogram counts how often every measurement was seen. Measurements are
+// bounded and have a single fractional digit, so there is a bucket for every
+// possible value and percentiles read from it are exact, the same as sorting
+// every measurement would give.
+//
+// Counts are 32 bits, which keeps it at 8 KiB per station per worker. A bucket
+// that wraps around carries into high, allocated the first time that happens,
+// so counts stay exact past 2³² measurements of the same value.
+type Histogram struct {
+	low  [HistogramBuckets]uint32
+	high *[HistogramBuckets]uint32
+}
+
+// Add counts a measurement of v tenths, v must be in -999..999.
+func (h *Histogram) Add(v int16) {
+	i := int(v) + 999
+	h.low[i]++
+	if h.low[i] == 0 {
+		h.carry(i, 1)
+	}
+}
+
+func (h *Histogram) carry(i int, n uint32) {
+	if h.high == nil {
+		h.high = new([HistogramBuckets]uint32)
+	}
+	h.high[i] += n
+}
+
+// Count returns how many measurements of v tenths h counted.
+func (h *Histogram) Count(v int16) uint64 {
+	return h.count(int(v) + 999)
+}
+
+func (h *Histogram) count(i int) uint64 {
+	c := uint64(h.low[i])
+	if h.high != nil {
+		c += uint64(h.high[i]) << 32
+	}
+
+	return c
+}
+
+// Merge adds the counts of o to h.
+func (h *Histogram) Merge(o *Histogram) {
+	for i, c := range o.low {
+		sum := uint64(h.low[i]) + uint64(c)
+		h.low[i] = uint32(sum)
+
+		carry := uint32(sum >> 32)
+		if o.high != nil {
+			carry += o.high[i]
+		}
+		if carry != 0 {
+			h.carry(i, carry)
+		}
+	}
+}
+
+// Equal reports whether h and o counted the same measurements.
+func (h *Histogram) Equal(o *Histogram) bool {
+	if h.low != o.low {
+		return false
+	}
+
+	for i := range h.low {
+		if h.count(i) != o.count(i) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Quantile returns the measurement at quantile q, 0 < q <= 1, of the count
+// measurements in h, in tenths. It is the nearest rank one: the smallest
+// measurement that at least q of all measurements are less than or equal to.
+func (h *Histogram) Quantile(q float64, count int) int16 {
+	rank := uint64(max(math.Ceil(q*float64(count)), 1))
+
+	var seen uint64
+	for i := range h.low {
+		seen += h.count(i)
+		if seen >= rank {
+			return int16(i - 999)
+		}
+	}
+
+	return 999
+}
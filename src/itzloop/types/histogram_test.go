@@ -0,0 +1,114 @@
+package types
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogram(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	var (
+		values []int16
+		h      Histogram
+	)
+	for i := 0; i < 10_001; i++ {
+		v := int16(max(min(math.Round(rng.NormFloat64()*100+150), 999), -999))
+		values = append(values, v)
+		h.Add(v)
+	}
+	slices.Sort(values)
+
+	for _, q := range []float64{0.0001, 0.25, 0.5, 0.9, 0.99, 0.999, 1} {
+		rank := int(math.Ceil(q * float64(len(values))))
+		assert.Equal(t, values[rank-1], h.Quantile(q, len(values)), "q=%v", q)
+	}
+
+	t.Run("bounds", func(t *testing.T) {
+		var h Histogram
+		h.Add(-999)
+		h.Add(999)
+		assert.EqualValues(t, -999, h.Quantile(0.5, 2))
+		assert.EqualValues(t, 999, h.Quantile(1, 2))
+	})
+
+	t.Run("carry", func(t *testing.T) {
+		assert.LessOrEqual(t, unsafe.Sizeof(Histogram{}), uintptr(8*1024))
+
+		var a, b Histogram
+		a.low[999+5] = math.MaxUint32
+		a.Add(5)
+		require.NotNil(t, a.high)
+		assert.EqualValues(t, 1<<32, a.Count(5))
+
+		b.low[999+5] = math.MaxUint32
+		b.Add(-3)
+		b.Merge(&a)
+		assert.EqualValues(t, 1<<32+math.MaxUint32, b.Count(5))
+		assert.EqualValues(t, 1, b.Count(-3))
+		assert.EqualValues(t, 5, b.Quantile(0.5, 1<<33))
+		assert.EqualValues(t, -3, b.Quantile(1e-12, 1<<33))
+
+		var c Histogram
+		c.Merge(&a)
+		assert.True(t, c.Equal(&a))
+		c.Add(5)
+		assert.False(t, c.Equal(&a))
+	})
+}
+
+func TestDistribution(t *testing.T) {
+	assert.Nil(t, NewDistribution(AgOptions{}))
+
+	opts := AgOptions{Histogram: true}
+	a, b := NewDistribution(opts), NewDistribution(opts)
+	for _, v := range []int16{10, 20, 30} {
+		a.Add(v)
+	}
+	for _, v := range []int16{40, -999} {
+		b.Add(v)
+	}
+
+	// merging into an empty Distribution copies what the others keep
+	d := &Distribution{}
+	d.Merge(a)
+	d.Merge(b)
+	require.NotNil(t, d.Hist)
+	assert.NotSame(t, a.Hist, d.Hist)
+	assert.Nil(t, d.Sketch)
+
+	p50, ok := d.Quantile(0.5, 5)
+	require.True(t, ok)
+	assert.Equal(t, 2.0, p50)
+
+	p99, ok := d.Quantile(0.99, 5)
+	require.True(t, ok)
+	assert.Equal(t, 4.0, p99)
+
+	_, ok = (*Distribution)(nil).Quantile(0.5, 5)
+	assert.False(t, ok)
+	_, ok = d.Quantile(0.5, 0)
+	assert.False(t, ok)
+
+	t.Run("equal", func(t *testing.T) {
+		assert.True(t, (*Distribution)(nil).Equal(nil))
+		assert.False(t, a.Equal(nil))
+		assert.False(t, (*Distribution)(nil).Equal(a))
+
+		c := NewDistribution(opts)
+		for _, v := range []int16{30, 10, 20} {
+			c.Add(v)
+		}
+		assert.True(t, c.Equal(a))
+		assert.False(t, c.Equal(NewDistribution(AgOptions{Histogram: true, Sketch: true})))
+
+		c.Hist.Add(0)
+		assert.False(t, c.Equal(a))
+	})
+}
@@ -0,0 +1,142 @@
+package types
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SortKey is what Result.Query orders stations by.
+type SortKey string
+
+const (
+	SortStation SortKey = "station" // by name, the 1BRC order
+	SortMin     SortKey = "min"
+	SortMean    SortKey = "mean"
+	SortMax     SortKey = "max"
+	SortCount   SortKey = "count"
+	SortRange   SortKey = "range" // max - min
+)
+
+// sortKeys compare the measures of two stations. SortStation compares their
+// names instead, which is what ties are broken with anyway.
+var sortKeys = map[SortKey]func(a, b *AgMeasures) int{
+	SortStation: nil,
+	SortMin:     func(a, b *AgMeasures) int { return cmp.Compare(a.Min, b.Min) },
+	SortMean:    func(a, b *AgMeasures) int { return cmp.Compare(a.Mean(), b.Mean()) },
+	SortMax:     func(a, b *AgMeasures) int { return cmp.Compare(a.Max, b.Max) },
+	SortCount:   func(a, b *AgMeasures) int { return cmp.Compare(a.Count, b.Count) },
+	SortRange: func(a, b *AgMeasures) int {
+		return cmp.Compare(int(a.Max)-int(a.Min), int(b.Max)-int(b.Min))
+	},
+}
+
+// SortKeys returns the names of the keys Result.Query can sort by in sorted
+// order.
+func SortKeys() []string {
+	names := make([]string, 0, len(sortKeys))
+	for k := range sortKeys {
+		names = append(names, string(k))
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// Query picks which stations of a Result are written and in what order.
+// The zero Query is every station by name, same as the Result itself.
+type Query struct {
+	// By is the statistic stations are sorted by, stations that tie are
+	// sorted by name. Empty means SortStation.
+	By SortKey
+
+	// Desc sorts from the largest value down. Ties stay in name order, but
+	// SortStation itself is reversed.
+	Desc bool
+
+	// Top keeps the first Top stations after sorting, 0 keeps all of them.
+	Top int
+}
+
+// ParseQuery builds a Query out of a sort key, optionally followed by :asc
+// or :desc like mean:desc, and a top limit.
+func ParseQuery(sort string, top int) (Query, error) {
+	key, dir, _ := strings.Cut(sort, ":")
+
+	q := Query{By: SortKey(key), Top: top}
+	switch dir {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return Query{}, fmt.Errorf("unknown sort direction %q, use asc or desc", dir)
+	}
+
+	if top < 0 {
+		return Query{}, fmt.Errorf("top must not be negative, got %d", top)
+	}
+
+	return q, q.validate()
+}
+
+func (q Query) validate() error {
+	if _, ok := sortKeys[q.By]; !ok && q.By != "" {
+		return fmt.Errorf("unknown sort key %q, available keys are: %s", q.By, strings.Join(SortKeys(), ", "))
+	}
+
+	return nil
+}
+
+// Query returns a Result with the stations of r that q picks, in the order
+// q puts them in. Measures are shared with r, not copied. Rows, Bytes and
+// Stats stay those of r, and every file in Files is queried the same way.
+func (r *Result) Query(q Query) (*Result, error) {
+	if err := q.validate(); err != nil {
+		return nil, err
+	}
+
+	by := sortKeys[q.By]
+
+	order := make([]string, 0, len(r.Measures))
+	for k := range r.Measures {
+		order = append(order, k)
+	}
+	slices.SortFunc(order, func(a, b string) int {
+		c := strings.Compare(a, b)
+		if by != nil {
+			c = by(r.Measures[a], r.Measures[b])
+		}
+		if q.Desc {
+			c = -c
+		}
+		return cmp.Or(c, strings.Compare(a, b))
+	})
+
+	if q.Top > 0 && q.Top < len(order) {
+		order = order[:q.Top]
+	}
+
+	qr := *r
+	qr.order = order
+	qr.Measures = make(AgMeasureMap, len(order))
+	for _, k := range order {
+		qr.Measures[k] = r.Measures[k]
+	}
+
+	if r.Distributions != nil {
+		qr.Distributions = make(map[string]*Distribution, len(order))
+		for _, k := range order {
+			qr.Distributions[k] = r.Distributions[k]
+		}
+	}
+
+	if r.Files != nil {
+		qr.Files = make(map[string]*Result, len(r.Files))
+		for p, f := range r.Files {
+			qr.Files[p], _ = f.Query(q) // q is valid
+		}
+	}
+
+	return &qr, nil
+}
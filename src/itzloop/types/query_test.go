@@ -0,0 +1,93 @@
+package types
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultQuery(t *testing.T) {
+	r := NewResult(AgMeasureMap{
+		"Abha":     {Min: -50, Max: 400, Sum: 450, Count: 3},
+		"Hamburg":  {Min: -100, Max: 200, Sum: 100, Count: 5},
+		"Istanbul": {Min: 62, Max: 230, Sum: 292, Count: 2},
+		"Oslo":     {Min: -150, Max: 100, Sum: -50, Count: 5},
+	}, 42)
+
+	table := []struct {
+		query    Query
+		expected []string
+	}{
+		{query: Query{}, expected: []string{"Abha", "Hamburg", "Istanbul", "Oslo"}},
+		{query: Query{Desc: true}, expected: []string{"Oslo", "Istanbul", "Hamburg", "Abha"}},
+		{query: Query{By: SortMean}, expected: []string{"Oslo", "Hamburg", "Istanbul", "Abha"}},
+		{query: Query{By: SortMean, Desc: true, Top: 2}, expected: []string{"Abha", "Istanbul"}},
+		{query: Query{By: SortMin}, expected: []string{"Oslo", "Hamburg", "Abha", "Istanbul"}},
+		{query: Query{By: SortMax, Desc: true}, expected: []string{"Abha", "Istanbul", "Hamburg", "Oslo"}},
+		{query: Query{By: SortRange, Top: 1}, expected: []string{"Istanbul"}},
+		// ties stay in name order either way
+		{query: Query{By: SortCount}, expected: []string{"Istanbul", "Abha", "Hamburg", "Oslo"}},
+		{query: Query{By: SortCount, Desc: true}, expected: []string{"Hamburg", "Oslo", "Abha", "Istanbul"}},
+		{query: Query{Top: 10}, expected: []string{"Abha", "Hamburg", "Istanbul", "Oslo"}},
+	}
+
+	for _, tc := range table {
+		t.Run(string(tc.query.By), func(t *testing.T) {
+			got, err := r.Query(tc.query)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, got.Stations())
+			assert.Len(t, got.Measures, len(tc.expected))
+			assert.Equal(t, r.Rows, got.Rows)
+			assert.Equal(t, r.Bytes, got.Bytes)
+		})
+	}
+
+	// r itself isn't touched
+	assert.Equal(t, []string{"Abha", "Hamburg", "Istanbul", "Oslo"}, r.Stations())
+
+	t.Run("output", func(t *testing.T) {
+		got, err := r.Query(Query{By: SortMax, Top: 2})
+		require.NoError(t, err)
+		assert.Equal(t, "{Oslo=-15.0/-1.0/10.0, Hamburg=-10.0/2.0/20.0}", got.String())
+
+		var buf bytes.Buffer
+		require.NoError(t, CSVEncoder{Comma: ','}.Encode(&buf, got))
+		assert.Equal(t, "station,min,mean,max,count\nOslo,-15.0,-1.0,10.0,5\nHamburg,-10.0,2.0,20.0,5\n", buf.String())
+	})
+
+	t.Run("files", func(t *testing.T) {
+		withFiles := *r
+		withFiles.Files = map[string]*Result{"a.txt": r}
+
+		got, err := withFiles.Query(Query{By: SortCount, Top: 1})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Istanbul"}, got.Files["a.txt"].Stations())
+	})
+
+	_, err := r.Query(Query{By: "median"})
+	assert.Error(t, err)
+}
+
+func TestParseQuery(t *testing.T) {
+	q, err := ParseQuery("mean:desc", 10)
+	require.NoError(t, err)
+	assert.Equal(t, Query{By: SortMean, Desc: true, Top: 10}, q)
+
+	q, err = ParseQuery("", 0)
+	require.NoError(t, err)
+	assert.Equal(t, Query{}, q)
+
+	q, err = ParseQuery("range:asc", 0)
+	require.NoError(t, err)
+	assert.Equal(t, Query{By: SortRange}, q)
+
+	for _, sort := range []string{"median", "mean:down"} {
+		_, err := ParseQuery(sort, 0)
+		assert.Error(t, err, sort)
+	}
+
+	_, err = ParseQuery("max", -1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,54 @@
+package types
+
+import (
+	"math"
+	"strconv"
+)
+
+// Round rounds v to one fractional digit the way the reference
+// implementation does, Math.round(v * 10.0) / 10.0. Java's Math.round rounds
+// half toward positive infinity, so 0.25 becomes 0.3 and -0.25 becomes -0.2,
+// where %.1f would round half to even on the binary value instead.
+//
+// Math.round returns a long, so the result is never negative zero.
+func Round(v float64) float64 {
+	scaled := v * 10.0
+	r := math.Floor(scaled)
+	if scaled-r >= 0.5 { // exact, scaled and r are within 1 of each other
+		r++
+	}
+
+	if r == 0 {
+		r = 0 // drop the sign of -0
+	}
+
+	return r / 10.0
+}
+
+// formatMeasure formats a value that is not a whole number of tenths, such as
+// a standard deviation, with the same rounding as the 1BRC output.
+func formatMeasure(v float64) string {
+	return strconv.FormatFloat(Round(v), 'f', 1, 64)
+}
+
+// formatTenths formats v tenths with one fractional digit. It is exact and
+// never produces negative zero.
+func formatTenths(v int64) string {
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+
+	return sign + strconv.FormatInt(v/10, 10) + "." + strconv.FormatInt(v%10, 10)
+}
+
+// floorDiv is a / b rounded toward negative infinity, b must be positive.
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+
+	return q
+}
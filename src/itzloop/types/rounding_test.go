@@ -0,0 +1,133 @@
+package types
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRound(t *testing.T) {
+	table := []struct {
+		v        float64
+		expected string
+	}{
+		{v: 0.25, expected: "0.3"},
+		{v: -0.25, expected: "-0.2"},
+		{v: 0.05, expected: "0.1"},
+		{v: -0.05, expected: "0.0"},
+		{v: -0.04, expected: "0.0"},
+		{v: -0.0, expected: "0.0"},
+		{v: 1.25, expected: "1.3"},
+		{v: -1.25, expected: "-1.2"},
+		{v: 99.85, expected: "99.9"},
+		{v: -5.15, expected: "-5.1"},
+		{v: 12.0, expected: "12.0"},
+		{v: -99.9, expected: "-99.9"},
+	}
+
+	for _, tc := range table {
+		t.Run(strconv.FormatFloat(tc.v, 'g', -1, 64), func(t *testing.T) {
+			assert.Equal(t, tc.expected, formatMeasure(tc.v))
+		})
+	}
+}
+
+// aggregate builds an AgMeasureMap out of 1BRC formatted rows.
+func aggregate(t *testing.T, rows string) AgMeasureMap {
+	t.Helper()
+
+	ag := AgMeasureMap{}
+	for _, line := range strings.Split(strings.TrimSpace(rows), "\n") {
+		station, measure, ok := strings.Cut(line, ";")
+		require.True(t, ok, line)
+
+		v, err := strconv.ParseFloat(measure, 64)
+		require.NoError(t, err, line)
+
+		m, ok := ag[station]
+		if !ok {
+			m = NewAgMeasures()
+			ag[station] = m
+		}
+		m.Add(int16(math.Round(v * 10)))
+	}
+
+	return ag
+}
+
+// TestConformance checks SortedString against outputs of the reference
+// implementation, CalculateAverage.java, for distributions where rounding
+// half to even and rounding half up disagree.
+func TestConformance(t *testing.T) {
+	table := []struct {
+		name     string
+		rows     string
+		expected string
+	}{
+		{
+			name:     "mean -0.05 rounds to 0.0",
+			rows:     "a;-0.1\na;0.0\n",
+			expected: "{a=-0.1/0.0/0.0}",
+		},
+		{
+			name:     "mean -0.025 has no negative zero",
+			rows:     "a;-0.1\na;0.1\na;-0.1\na;0.0\n",
+			expected: "{a=-0.1/0.0/0.1}",
+		},
+		{
+			name:     "negative zero measurement",
+			rows:     "a;-0.0\n",
+			expected: "{a=0.0/0.0/0.0}",
+		},
+		{
+			name:     "mean 0.25 rounds up",
+			rows:     "a;0.2\na;0.3\n",
+			expected: "{a=0.2/0.3/0.3}",
+		},
+		{
+			name:     "mean 1.25 rounds up",
+			rows:     "a;1.2\na;1.3\n",
+			expected: "{a=1.2/1.3/1.3}",
+		},
+		{
+			name:     "mean -1.25 rounds toward positive infinity",
+			rows:     "a;-1.2\na;-1.3\n",
+			expected: "{a=-1.3/-1.2/-1.2}",
+		},
+		{
+			name:     "mean 99.85",
+			rows:     "a;99.9\na;99.8\n",
+			expected: "{a=99.8/99.9/99.9}",
+		},
+		{
+			name:     "mean -5.15",
+			rows:     "a;-5.1\na;-5.2\n",
+			expected: "{a=-5.2/-5.1/-5.1}",
+		},
+		{
+			name:     "mean 10.25 over four rows",
+			rows:     "a;10.1\na;10.2\na;10.3\na;10.4\n",
+			expected: "{a=10.1/10.3/10.4}",
+		},
+		{
+			name:     "extremes",
+			rows:     "a;-99.9\na;99.9\nb;-99.9\n",
+			expected: "{a=-99.9/0.0/99.9, b=-99.9/-99.9/-99.9}",
+		},
+		{
+			name:     "sorted by station name bytes",
+			rows:     "Zürich;1.0\nAbéché;2.0\nAbha;3.0\nSt. John's;4.0\n",
+			expected: "{Abha=3.0/3.0/3.0, Abéché=2.0/2.0/2.0, St. John's=4.0/4.0/4.0, Zürich=1.0/1.0/1.0}",
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, aggregate(t, tc.rows).SortedString())
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package types
+
+import "math"
+
+// SketchRelativeAccuracy is how far off, relative to the true value, a
+// quantile read from a Sketch can be.
+const SketchRelativeAccuracy = 0.01
+
+// sketchMinValue is the smallest magnitude a Sketch tells apart from zero.
+const sketchMinValue = 1e-9
+
+var (
+	sketchGamma    = (1 + SketchRelativeAccuracy) / (1 - SketchRelativeAccuracy)
+	sketchLogGamma = math.Log(sketchGamma)
+)
+
+// Sketch is a DDSketch: it counts values in buckets whose bounds grow
+// geometrically, so any value is within SketchRelativeAccuracy of the middle
+// of its bucket. Unlike Histogram it works for values of any precision and
+// range, and merging two sketches is adding up their buckets, so the result
+// doesn't depend on how the values were split between workers.
+type Sketch struct {
+	pos, neg sketchStore
+	zero     uint64
+	count    uint64
+}
+
+// NewSketch returns an empty Sketch.
+func NewSketch() *Sketch {
+	return &Sketch{}
+}
+
+// Add counts the value v.
+func (s *Sketch) Add(v float64) {
+	switch {
+	case v >= sketchMinValue:
+		s.pos.add(sketchIndex(v), 1)
+	case v <= -sketchMinValue:
+		s.neg.add(sketchIndex(-v), 1)
+	default:
+		s.zero++
+	}
+	s.count++
+}
+
+// Merge adds the values counted in o to s.
+func (s *Sketch) Merge(o *Sketch) {
+	s.pos.merge(&o.pos)
+	s.neg.merge(&o.neg)
+	s.zero += o.zero
+	s.count += o.count
+}
+
+// Count returns the number of values in s.
+func (s *Sketch) Count() uint64 {
+	return s.count
+}
+
+// Quantile returns the value at quantile q, 0 < q <= 1, with the same nearest
+// rank definition as Histogram.Quantile. It is NaN when s is empty.
+func (s *Sketch) Quantile(q float64) float64 {
+	if s.count == 0 {
+		return math.NaN()
+	}
+
+	rank := uint64(max(math.Ceil(q*float64(s.count)), 1))
+
+	// the most negative values are in the highest buckets of neg
+	var seen uint64
+	for j := len(s.neg.bins) - 1; j >= 0; j-- {
+		seen += s.neg.bins[j]
+		if seen >= rank {
+			return -sketchValue(s.neg.offset + j)
+		}
+	}
+
+	seen += s.zero
+	if seen >= rank {
+		return 0
+	}
+
+	for j, c := range s.pos.bins {
+		seen += c
+		if seen >= rank {
+			return sketchValue(s.pos.offset + j)
+		}
+	}
+
+	return sketchValue(s.pos.offset + len(s.pos.bins) - 1)
+}
+
+// Equal reports whether s and o counted the same buckets.
+func (s *Sketch) Equal(o *Sketch) bool {
+	return s.count == o.count && s.zero == o.zero && s.pos.equal(&o.pos) && s.neg.equal(&o.neg)
+}
+
+// sketchIndex returns the bucket of v, which must be positive.
+func sketchIndex(v float64) int {
+	return int(math.Ceil(math.Log(v) / sketchLogGamma))
+}
+
+// sketchValue returns the value in bucket i that is closest, relative to
+// their size, to both of the bucket bounds.
+func sketchValue(i int) float64 {
+	return 2 * math.Exp(float64(i)*sketchLogGamma) / (sketchGamma + 1)
+}
+
+// sketchStore holds the counts of the buckets offset to offset+len(bins)-1.
+type sketchStore struct {
+	offset int
+	bins   []uint64
+}
+
+func (ss *sketchStore) add(i int, c uint64) {
+	switch {
+	case len(ss.bins) == 0:
+		ss.offset = i
+		ss.bins = make([]uint64, 1)
+	case i < ss.offset:
+		bins := make([]uint64, ss.offset-i+len(ss.bins))
+		copy(bins[ss.offset-i:], ss.bins)
+		ss.bins, ss.offset = bins, i
+	case i >= ss.offset+len(ss.bins):
+		ss.bins = append(ss.bins, make([]uint64, i-ss.offset-len(ss.bins)+1)...)
+	}
+
+	ss.bins[i-ss.offset] += c
+}
+
+func (ss *sketchStore) merge(o *sketchStore) {
+	if len(o.bins) == 0 {
+		return
+	}
+
+	// grow once to cover both ends of o
+	ss.add(o.offset, 0)
+	ss.add(o.offset+len(o.bins)-1, 0)
+	for j, c := range o.bins {
+		ss.bins[o.offset+j-ss.offset] += c
+	}
+}
+
+// equal compares the counts of two stores, which can cover different ranges
+// of empty buckets.
+func (ss *sketchStore) equal(o *sketchStore) bool {
+	lo := min(ss.firstIndex(), o.firstIndex())
+	hi := max(ss.offset+len(ss.bins), o.offset+len(o.bins))
+	for i := lo; i < hi; i++ {
+		if ss.count(i) != o.count(i) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (ss *sketchStore) firstIndex() int {
+	if len(ss.bins) == 0 {
+		return math.MaxInt
+	}
+
+	return ss.offset
+}
+
+func (ss *sketchStore) count(i int) uint64 {
+	if i < ss.offset || i >= ss.offset+len(ss.bins) {
+		return 0
+	}
+
+	return ss.bins[i-ss.offset]
+}
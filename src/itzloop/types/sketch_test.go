@@ -0,0 +1,143 @@
+package types
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSketch(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	var (
+		values []float64
+		s      = NewSketch()
+	)
+	for i := 0; i < 10_001; i++ {
+		v := math.Round(rng.NormFloat64()*100+150) / 10
+		values = append(values, v)
+		s.Add(v)
+	}
+	slices.Sort(values)
+	require.EqualValues(t, len(values), s.Count())
+
+	for _, q := range []float64{0.0001, 0.25, 0.5, 0.9, 0.99, 0.999, 1} {
+		want := values[int(math.Ceil(q*float64(len(values))))-1]
+		assert.InDelta(t, want, s.Quantile(q), SketchRelativeAccuracy*math.Abs(want)+1e-9, "q=%v", q)
+	}
+
+	t.Run("zero and negatives", func(t *testing.T) {
+		s := NewSketch()
+		for _, v := range []float64{-99.9, -0.1, 0, 0, 12.5} {
+			s.Add(v)
+		}
+		assert.InEpsilon(t, -99.9, s.Quantile(0.2), SketchRelativeAccuracy)
+		assert.InEpsilon(t, -0.1, s.Quantile(0.4), SketchRelativeAccuracy)
+		assert.Equal(t, 0.0, s.Quantile(0.6))
+		assert.InEpsilon(t, 12.5, s.Quantile(1), SketchRelativeAccuracy)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		assert.True(t, math.IsNaN(NewSketch().Quantile(0.5)))
+	})
+}
+
+func TestSketchMerge(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+
+	var (
+		all   = NewSketch()
+		parts = []*Sketch{NewSketch(), NewSketch(), NewSketch()}
+	)
+	for i := 0; i < 3_000; i++ {
+		v := math.Round(rng.NormFloat64()*300) / 10
+		all.Add(v)
+		parts[rng.IntN(len(parts))].Add(v)
+	}
+
+	// merging in any order gives the same sketch as adding every value to one
+	for _, order := range [][]int{{0, 1, 2}, {2, 1, 0}, {1, 2, 0}} {
+		m := NewSketch()
+		for _, i := range order {
+			m.Merge(parts[i])
+		}
+		assert.True(t, m.Equal(all), "order %v", order)
+		assert.Equal(t, all.Quantile(0.9), m.Quantile(0.9))
+	}
+
+	assert.False(t, parts[0].Equal(all))
+}
+
+func TestDistributionSketch(t *testing.T) {
+	opts := AgOptions{Sketch: true}
+	a, b := NewDistribution(opts), NewDistribution(opts)
+	for _, v := range []int16{10, 20, 30} {
+		a.Add(v)
+	}
+	for _, v := range []int16{40, -999} {
+		b.Add(v)
+	}
+
+	d := &Distribution{}
+	d.Merge(a)
+	d.Merge(b)
+	require.NotNil(t, d.Sketch)
+	assert.Nil(t, d.Hist)
+	assert.NotSame(t, a.Sketch, d.Sketch)
+
+	p50, ok := d.Quantile(0.5, 5)
+	require.True(t, ok)
+	assert.InEpsilon(t, 2.0, p50, SketchRelativeAccuracy)
+
+	// the exact histogram wins when both are kept
+	h := NewDistribution(AgOptions{Histogram: true, Sketch: true})
+	h.Add(21)
+	p50, ok = h.Quantile(0.5, 1)
+	require.True(t, ok)
+	assert.Equal(t, 2.1, p50)
+
+	assert.False(t, a.Equal(NewDistribution(AgOptions{Histogram: true})))
+}
+
+func TestDistributionAddFloat(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+
+	// way past -99.9..99.9 and with more than one fractional digit
+	var values []float64
+	for i := 0; i < 20_000; i++ {
+		values = append(values, rng.NormFloat64()*5_000+1_234.5678)
+	}
+	values = append(values, 0.000123, -987_654.321, 1e9)
+
+	opts := AgOptions{Sketch: true}
+	all := NewDistribution(opts)
+	parts := []*Distribution{NewDistribution(opts), NewDistribution(opts)}
+	for i, v := range values {
+		all.AddFloat(v)
+		parts[i%2].AddFloat(v)
+	}
+
+	merged := &Distribution{}
+	for _, p := range parts {
+		merged.Merge(p)
+	}
+	assert.True(t, all.Equal(merged))
+
+	slices.Sort(values)
+	count := int(all.Sketch.Count())
+	require.Equal(t, len(values), count)
+	for _, q := range []float64{0.00001, 0.01, 0.25, 0.5, 0.9, 0.99, 1} {
+		want := values[int(math.Ceil(q*float64(len(values))))-1]
+		got, ok := merged.Quantile(q, count)
+		require.True(t, ok)
+		assert.InDelta(t, want, got, SketchRelativeAccuracy*math.Abs(want)+1e-9, "q=%v", q)
+	}
+
+	assert.Panics(t, func() {
+		NewDistribution(AgOptions{Histogram: true, Sketch: true}).AddFloat(1.25)
+	})
+}
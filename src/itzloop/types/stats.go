@@ -0,0 +1,120 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Stats is where a processor spent its time during a run. Read and Parse are
+// summed over the readers and workers doing them in parallel, so stages can
+// add up to more than Wall.
+type Stats struct {
+	Wall      time.Duration `json:"wall"`
+	Split     time.Duration `json:"split"` // finding chunk boundaries
+	Read      time.Duration `json:"read"`
+	Parse     time.Duration `json:"parse"`
+	Aggregate time.Duration `json:"aggregate"` // merging worker maps
+
+	// AggregatorBacklog is the most worker maps that were waiting for the
+	// aggregator at once.
+	AggregatorBacklog int `json:"aggregator_backlog"`
+
+	Readers []ReaderStats `json:"readers"`
+	Workers []WorkerStats `json:"workers"`
+}
+
+// ReaderStats is what a single reader goroutine did.
+type ReaderStats struct {
+	Bytes int64         `json:"bytes"`
+	Reads int           `json:"reads"`
+	Read  time.Duration `json:"read"`
+
+	// Send is the time spent handing blocks to the workers, which is mostly
+	// waiting for room in their channel when they can't keep up.
+	Send time.Duration `json:"send"`
+}
+
+// WorkerStats is what a single worker goroutine did.
+type WorkerStats struct {
+	Bytes int64 `json:"bytes"`
+
+	// Rows is the number of measurements aggregated, rows of stations the
+	// filter skipped are not counted, same as in Result.Rows.
+	Rows   int64         `json:"rows"`
+	Blocks int           `json:"blocks"`
+	Parse  time.Duration `json:"parse"`
+
+	// Wait is the time spent waiting for blocks, a worker starved by the
+	// readers waits a lot.
+	Wait time.Duration `json:"wait"`
+}
+
+// NewStats returns Stats for a run with the given number of readers and
+// workers.
+func NewStats(readers, workers int) *Stats {
+	return &Stats{
+		Readers: make([]ReaderStats, readers),
+		Workers: make([]WorkerStats, workers),
+	}
+}
+
+// Sum fills in Read and Parse from the readers and workers.
+func (s *Stats) Sum() {
+	s.Read, s.Parse = 0, 0
+	for _, r := range s.Readers {
+		s.Read += r.Read
+	}
+	for _, w := range s.Workers {
+		s.Parse += w.Parse
+	}
+}
+
+// Merge adds the stats of another run to s. Readers and workers are matched
+// by index.
+func (s *Stats) Merge(o *Stats) {
+	s.Wall += o.Wall
+	s.Split += o.Split
+	s.Aggregate += o.Aggregate
+	s.AggregatorBacklog = max(s.AggregatorBacklog, o.AggregatorBacklog)
+
+	for len(s.Readers) < len(o.Readers) {
+		s.Readers = append(s.Readers, ReaderStats{})
+	}
+	for i, r := range o.Readers {
+		s.Readers[i].Bytes += r.Bytes
+		s.Readers[i].Reads += r.Reads
+		s.Readers[i].Read += r.Read
+		s.Readers[i].Send += r.Send
+	}
+
+	for len(s.Workers) < len(o.Workers) {
+		s.Workers = append(s.Workers, WorkerStats{})
+	}
+	for i, w := range o.Workers {
+		s.Workers[i].Bytes += w.Bytes
+		s.Workers[i].Rows += w.Rows
+		s.Workers[i].Blocks += w.Blocks
+		s.Workers[i].Parse += w.Parse
+		s.Workers[i].Wait += w.Wait
+	}
+
+	s.Sum()
+}
+
+// String returns the stats as a few lines meant for humans.
+func (s *Stats) String() string {
+	str := strings.Builder{}
+	fmt.Fprintf(&str, "wall %s, split %s, read %s, parse %s, aggregate %s, aggregator backlog %d\n",
+		s.Wall, s.Split, s.Read, s.Parse, s.Aggregate, s.AggregatorBacklog)
+
+	for i, r := range s.Readers {
+		fmt.Fprintf(&str, "reader %d: %d bytes in %d reads, read %s, send %s\n", i, r.Bytes, r.Reads, r.Read, r.Send)
+	}
+
+	for i, w := range s.Workers {
+		fmt.Fprintf(&str, "worker %d: %d bytes, %d rows in %d blocks, parse %s, wait %s\n", i, w.Bytes, w.Rows, w.Blocks, w.Parse, w.Wait)
+	}
+
+	return str.String()
+}
@@ -1,24 +1,226 @@
 package utils
 
+import (
+	"bytes"
 
-// 10_000 station name with 
+	"github.com/itzloop/1brc/types"
+)
+
+// FNV-1a, cheap enough to compute byte by byte while scanning for ';'.
+const (
+	HashOffset uint64 = 14695981039346656037
+	hashPrime  uint64 = 1099511628211
+)
+
+// HashByte adds c to the hash h. Start with HashOffset.
+func HashByte(h uint64, c byte) uint64 {
+	return (h ^ uint64(c)) * hashPrime
+}
+
+// Hash hashes key the same way calling HashByte on every byte does.
+func Hash(key []byte) uint64 {
+	h := HashOffset
+	for _, c := range key {
+		h = HashByte(h, c)
+	}
+
+	return h
+}
+
+// MaxStations is the number of unique station names the challenge allows.
+const MaxStations = 10_000
+
+type entry struct {
+	used  bool
+	skip  bool  // the filter didn't pick the key
+	dist  int32 // index in CustomMap.dists, fits in the padding after the bools
+	hash  uint64
+	key   []byte
+	value types.AgMeasures
+}
+
+// CustomMap is an open addressing hash table with linear probing, keyed by
+// station name bytes so the hot loop never converts them to strings. The
+// aggregates live inline in the table, there is no pointer per station.
+// Callers pass in the hash of the key, which lets them compute it while they
+// are scanning the key anyway. Distributions, when AgOptions asks for any, are
+// kept on the side so the table stays the same size without them.
 type CustomMap struct {
-    data [][]interface{}
+	entries []entry
+	mask    uint64
+	len     int
+	skipped int
+	opts    types.AgOptions
+	dists   []*types.Distribution
+	filter  *types.StationFilter
 }
 
+// NewCustomMap returns a map that holds initialSize keys without growing.
+// Use MaxStations to never grow on challenge inputs.
 func NewCustomMap(initialSize int) *CustomMap {
-    return &CustomMap{
-        data: make([][]interface{}, 0, initialSize),
-    }
+	size := 1
+	for size < initialSize+initialSize/3 { // keep the load factor under 3/4
+		size <<= 1
+	}
+
+	return &CustomMap{
+		entries: make([]entry, size),
+		mask:    uint64(size - 1),
+	}
+}
+
+// NewCustomMapWith is NewCustomMap for keys that also have a Distribution
+// keeping what opts asks for.
+func NewCustomMapWith(initialSize int, opts types.AgOptions) *CustomMap {
+	m := NewCustomMap(initialSize)
+	m.opts = opts
+	return m
+}
+
+// SetFilter makes Select skip the keys f doesn't pick. Set it before the
+// first Select.
+func (m *CustomMap) SetFilter(f *types.StationFilter) {
+	m.filter = f
 }
 
-// func Get(key []byte) (interface{}, bool) {
-// }
+// Get returns the aggregates of key, hash must be Hash(key).
+func (m *CustomMap) Get(key []byte, hash uint64) (*types.AgMeasures, bool) {
+	for i := hash & m.mask; ; i = (i + 1) & m.mask {
+		e := &m.entries[i]
+		if !e.used {
+			return nil, false
+		}
+
+		if e.hash == hash && bytes.Equal(e.key, key) {
+			return &e.value, !e.skip
+		}
+	}
+}
+
+// Select is GetOrInsert for keys the filter picks and nil for the others.
+// Whether a key is picked is decided once, when it is first seen, and kept in
+// the table, so rows of skipped stations cost a lookup and nothing else. The
+// Distribution of the key is returned too, nil unless the map keeps them.
+func (m *CustomMap) Select(key []byte, hash uint64) (*types.AgMeasures, *types.Distribution) {
+	for i := hash & m.mask; ; i = (i + 1) & m.mask {
+		e := &m.entries[i]
+		if !e.used {
+			if m.filter.Match(key) {
+				m.GetOrInsert(key, hash)
+				return m.Select(key, hash)
+			}
+
+			if m.len+1 > len(m.entries)*3/4 {
+				m.grow()
+				return m.Select(key, hash)
+			}
 
-// func (m *CustomMap) Put(key []byte, val interface{}) {
-//     id := xxhash.Sum64(key)
-    
-//     if len()
+			e.used = true
+			e.skip = true
+			e.hash = hash
+			e.key = append(make([]byte, 0, len(key)), key...)
+			e.value = *types.NewAgMeasures()
+			m.len++
+			m.skipped++
+			return nil, nil
+		}
 
-//     m.data[id] = append(m.data[id], val)
-// }
+		if e.hash == hash && bytes.Equal(e.key, key) {
+			if e.skip {
+				return nil, nil
+			}
+			if m.dists == nil {
+				return &e.value, nil
+			}
+			return &e.value, m.dists[e.dist]
+		}
+	}
+}
+
+// GetOrInsert returns the aggregates of key, adding an empty one if key is
+// not in the map yet. hash must be Hash(key). key is copied on insert so the
+// caller is free to reuse its buffer. It doesn't look at the filter, maps that
+// have one go through Select: a key Select skipped stays out of Get, Range and
+// Len whatever GetOrInsert adds to it.
+func (m *CustomMap) GetOrInsert(key []byte, hash uint64) *types.AgMeasures {
+	for i := hash & m.mask; ; i = (i + 1) & m.mask {
+		e := &m.entries[i]
+		if !e.used {
+			if m.len+1 > len(m.entries)*3/4 {
+				m.grow()
+				return m.GetOrInsert(key, hash)
+			}
+
+			e.used = true
+			e.hash = hash
+			e.key = append(make([]byte, 0, len(key)), key...)
+			e.value = *types.NewAgMeasures()
+			m.addDistribution(e)
+			m.len++
+			return &e.value
+		}
+
+		if e.hash == hash && bytes.Equal(e.key, key) {
+			return &e.value
+		}
+	}
+}
+
+func (m *CustomMap) addDistribution(e *entry) {
+	if d := types.NewDistribution(m.opts); d != nil {
+		e.dist = int32(len(m.dists))
+		m.dists = append(m.dists, d)
+	}
+}
+
+func (m *CustomMap) grow() {
+	old := m.entries
+	m.entries = make([]entry, len(old)*2)
+	m.mask = uint64(len(m.entries) - 1)
+
+	for _, e := range old {
+		if !e.used {
+			continue
+		}
+
+		i := e.hash & m.mask
+		for m.entries[i].used {
+			i = (i + 1) & m.mask
+		}
+		m.entries[i] = e
+	}
+}
+
+// Len returns the number of keys in the map, not counting the ones Select
+// skipped.
+func (m *CustomMap) Len() int {
+	return m.len - m.skipped
+}
+
+// Range calls fn for every key in no particular order until fn returns false.
+// key must not be modified and d is nil unless the map keeps distributions.
+func (m *CustomMap) Range(fn func(key []byte, v *types.AgMeasures, d *types.Distribution) bool) {
+	for i := range m.entries {
+		e := &m.entries[i]
+		if !e.used || e.skip {
+			continue
+		}
+
+		var d *types.Distribution
+		if m.dists != nil {
+			d = m.dists[e.dist]
+		}
+		if !fn(e.key, &e.value, d) {
+			return
+		}
+	}
+}
+
+// Reset removes every key while keeping the allocated table.
+func (m *CustomMap) Reset() {
+	clear(m.entries)
+	clear(m.dists)
+	m.dists = m.dists[:0]
+	m.len = 0
+	m.skipped = 0
+}
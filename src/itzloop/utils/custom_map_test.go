@@ -1,8 +1,12 @@
 package utils
 
 import (
+	"fmt"
+	"strings"
 	"testing"
+	"unsafe"
 
+	"github.com/itzloop/1brc/types"
 )
 
 func BenchmarkSlice(b *testing.B) {
@@ -45,3 +49,231 @@ func BenchmarkMap(b *testing.B) {
 func TestUniqeness(t *testing.T) {
 }
 
+
+// stationKeys returns n distinct station names of 3 to 26 bytes.
+func stationKeys(n int) [][]byte {
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("%s-%d", strings.Repeat("x", i%20), i))
+	}
+
+	return keys
+}
+
+func TestCustomMap(t *testing.T) {
+	keys := stationKeys(MaxStations)
+
+	// start small so the map has to grow along the way
+	m := NewCustomMap(16)
+	for round := 0; round < 3; round++ {
+		for i, k := range keys {
+			m.GetOrInsert(k, Hash(k)).Add(int16(i % 1000))
+		}
+	}
+
+	if m.Len() != len(keys) {
+		t.Fatalf("expected %d keys but got %d", len(keys), m.Len())
+	}
+
+	for i, k := range keys {
+		v, ok := m.Get(k, Hash(k))
+		if !ok {
+			t.Fatalf("expected %s to be in the map", k)
+		}
+
+		if v.Count != 3 || v.Sum != 3*int64(i%1000) {
+			t.Errorf("%s: expected count 3 and sum %d but got %d and %d", k, 3*(i%1000), v.Count, v.Sum)
+		}
+	}
+
+	if _, ok := m.Get([]byte("missing"), Hash([]byte("missing"))); ok {
+		t.Errorf("expected missing key to not be in the map")
+	}
+
+	// keys are copied on insert
+	k := []byte("Hamburg")
+	m.GetOrInsert(k, Hash(k))
+	k[0] = 'X'
+	if _, ok := m.Get([]byte("Hamburg"), Hash([]byte("Hamburg"))); !ok {
+		t.Errorf("expected the map to keep its own copy of the key")
+	}
+
+	seen := 0
+	m.Range(func(key []byte, v *types.AgMeasures, d *types.Distribution) bool {
+		if d != nil {
+			t.Errorf("%s: expected no distribution without AgOptions", key)
+		}
+		seen++
+		return true
+	})
+	if seen != m.Len() {
+		t.Errorf("expected range to visit %d keys but visited %d", m.Len(), seen)
+	}
+
+	m.Reset()
+	if m.Len() != 0 {
+		t.Errorf("expected an empty map after reset but got %d keys", m.Len())
+	}
+}
+
+func TestCustomMapSelect(t *testing.T) {
+	keys := stationKeys(MaxStations)
+	f, err := types.NewStationFilter([]string{string(keys[1])}, []string{"xxxxx-"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// start small so skipped keys make the map grow too
+	m := NewCustomMap(16)
+	m.SetFilter(f)
+	picked := 0
+	for round := 0; round < 2; round++ {
+		for _, k := range keys {
+			v, _ := m.Select(k, Hash(k))
+			if v == nil {
+				continue
+			}
+			v.Add(1)
+			if round == 0 {
+				picked++
+			}
+		}
+	}
+
+	// keys[1] by name and keys[5], keys[25], ... by prefix
+	if want := 1 + MaxStations/20; picked != want || m.Len() != want {
+		t.Fatalf("expected %d picked keys but got %d and a length of %d", want, picked, m.Len())
+	}
+
+	if _, ok := m.Get(keys[0], Hash(keys[0])); ok {
+		t.Errorf("expected %s to be skipped", keys[0])
+	}
+
+	m.Range(func(key []byte, v *types.AgMeasures, _ *types.Distribution) bool {
+		if !f.Match(key) || v.Count != 2 {
+			t.Errorf("%s: expected a picked key with count 2 but got count %d", key, v.Count)
+		}
+		return true
+	})
+
+	// skipped keys stay skipped
+	if v, _ := m.Select(keys[0], Hash(keys[0])); v != nil {
+		t.Errorf("expected %s to still be skipped", keys[0])
+	}
+}
+
+func TestCustomMapDistributions(t *testing.T) {
+	keys := stationKeys(MaxStations)
+
+	// start small so distributions have to follow their keys as the map grows
+	m := NewCustomMapWith(16, types.AgOptions{Histogram: true})
+	for round := 0; round < 2; round++ {
+		for i, k := range keys {
+			v, d := m.Select(k, Hash(k))
+			v.Add(int16(i % 1000))
+			d.Add(int16(i % 1000))
+		}
+	}
+
+	seen := 0
+	m.Range(func(key []byte, v *types.AgMeasures, d *types.Distribution) bool {
+		seen++
+		if p, ok := d.Quantile(1, v.Count); !ok || p != float64(v.Max)/10 {
+			t.Errorf("%s: expected the distribution of %d tenths but got %v", key, v.Max, p)
+		}
+		return true
+	})
+	if seen != len(keys) {
+		t.Errorf("expected range to visit %d keys but visited %d", len(keys), seen)
+	}
+
+	// the default table doesn't grow with them
+	if size := unsafe.Sizeof(entry{}); size != 72 {
+		t.Errorf("expected entries of 72 bytes but got %d", size)
+	}
+}
+
+func TestHashByte(t *testing.T) {
+	key := []byte("St. John's")
+	h := HashOffset
+	for _, c := range key {
+		h = HashByte(h, c)
+	}
+
+	if h != Hash(key) {
+		t.Errorf("expected incremental hash %d to equal %d", h, Hash(key))
+	}
+}
+
+var agSink *types.AgMeasures
+
+// BenchmarkCustomMap and BenchmarkBuiltinMap look up stations the way the
+// processors do, CustomMap with the hash already in hand and the built-in map
+// through an unsafe.String key.
+func BenchmarkCustomMap(b *testing.B) {
+	for _, n := range []int{413, MaxStations} {
+		keys := stationKeys(n)
+		hashes := make([]uint64, n)
+		for i, k := range keys {
+			hashes[i] = Hash(k)
+		}
+
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			m := NewCustomMap(MaxStations)
+			var v *types.AgMeasures
+			for i := 0; i < b.N; i++ {
+				j := i % n
+				v = m.GetOrInsert(keys[j], hashes[j])
+				v.Add(int16(j))
+			}
+			agSink = v
+		})
+
+		b.Run(fmt.Sprintf("%d with histograms", n), func(b *testing.B) {
+			m := NewCustomMapWith(MaxStations, types.AgOptions{Histogram: true})
+			var v *types.AgMeasures
+			for i := 0; i < b.N; i++ {
+				j := i % n
+				var d *types.Distribution
+				v, d = m.Select(keys[j], hashes[j])
+				v.Add(int16(j%types.HistogramBuckets - 999))
+				d.Add(int16(j%types.HistogramBuckets - 999))
+			}
+			agSink = v
+		})
+
+		b.Run(fmt.Sprintf("%d with hashing", n), func(b *testing.B) {
+			m := NewCustomMap(MaxStations)
+			var v *types.AgMeasures
+			for i := 0; i < b.N; i++ {
+				j := i % n
+				v = m.GetOrInsert(keys[j], Hash(keys[j]))
+				v.Add(int16(j))
+			}
+			agSink = v
+		})
+	}
+}
+
+func BenchmarkBuiltinMap(b *testing.B) {
+	for _, n := range []int{413, MaxStations} {
+		keys := stationKeys(n)
+
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			m := map[string]*types.AgMeasures{}
+			var v *types.AgMeasures
+			for i := 0; i < b.N; i++ {
+				j := i % n
+				k := unsafe.String(&keys[j][0], len(keys[j]))
+				var ok bool
+				v, ok = m[k]
+				if !ok {
+					v = types.NewAgMeasures()
+					m[k] = v
+				}
+				v.Add(int16(j))
+			}
+			agSink = v
+		})
+	}
+}
@@ -63,3 +63,45 @@ func BtofV2(n []byte) (float32, error) {
 
     return 0, ErrWTF
 }
+
+var ErrInvalidMeasurement = errors.New("measurement is not in the -99.9..99.9 format")
+
+// ParseTenths parses a measurement with exactly one fractional digit, like
+// -12.3, into tenths, -123. It is BtofV2 without the floats and with the
+// digits checked.
+func ParseTenths(n []byte) (int16, error) {
+	l := len(n)
+	if l < 3 {
+		return 0, ErrFloatInvalidLenght
+	}
+
+	negative := n[0] == '-'
+	if negative {
+		n = n[1:]
+		l--
+	}
+
+	// n[l-1]: decimal digit, n[l-2]: decimal point, n[:l-2]: integer part
+	if l < 3 || l > 4 || n[l-2] != '.' {
+		return 0, ErrInvalidMeasurement
+	}
+
+	var v int16
+	for _, c := range n[:l-2] {
+		if c < '0' || c > '9' {
+			return 0, ErrInvalidMeasurement
+		}
+		v = v*10 + int16(c-'0')
+	}
+
+	d := n[l-1]
+	if d < '0' || d > '9' {
+		return 0, ErrInvalidMeasurement
+	}
+	v = v*10 + int16(d-'0')
+
+	if negative {
+		return -v, nil
+	}
+	return v, nil
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"math"
 	"strconv"
 	"testing"
 )
@@ -93,6 +94,23 @@ func BenchmarkBtofV2(b *testing.B) {
 	result, err = r, e
 }
 
+func BenchmarkParseTenths(b *testing.B) {
+	var (
+		r int16
+		e error
+	)
+
+	for _, bench := range table {
+		b.Run(string(bench.n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				r, e = ParseTenths(bench.n)
+			}
+		})
+	}
+
+	result, err = float32(r)/10, e
+}
+
 func BenchmarkParseFloat(b *testing.B) {
 	var (
 		r float64
@@ -109,3 +127,29 @@ func BenchmarkParseFloat(b *testing.B) {
 
 	result, err = float32(r), e
 }
+
+func TestParseTenths(t *testing.T) {
+	for _, test := range table {
+		t.Run(string(test.n), func(t *testing.T) {
+			result, err := ParseTenths(test.n)
+			if err != nil {
+				t.Errorf("expected to have no error but got: %v", err)
+				return
+			}
+
+			// test.result is a float32 so compare with its closest tenth
+			expected := int16(math.Round(float64(test.result) * 10))
+			if result != expected {
+				t.Errorf("expected to have %d but got %d", expected, result)
+			}
+		})
+	}
+
+	for _, n := range []string{"", "1.", "-1.", "100.0", "1,0", "a.0", "1.a", "--1.0", "1.00"} {
+		t.Run("invalid "+n, func(t *testing.T) {
+			if _, err := ParseTenths([]byte(n)); err == nil {
+				t.Errorf("expected %q to fail", n)
+			}
+		})
+	}
+}
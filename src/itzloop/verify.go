@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/itzloop/1brc/internal/processors"
+	"github.com/itzloop/1brc/types"
+)
+
+// verify is the verify subcommand. It runs processors and the naive baseline
+// on the same input and prints every station they disagree on.
+func verify(args []string) error {
+	cfg := processors.DefaultConfig()
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	inputPath := fs.String("i", "measurements.txt", "path to input file, glob or directory. More inputs can follow the flags")
+	processorName := fs.String("processor", "all", fmt.Sprintf("processor to check against naive, all or one of: %s", strings.Join(processors.Names(), "|")))
+	configFlags(fs, &cfg)
+	fs.Parse(args)
+
+	paths, err := processors.ExpandPaths(append([]string{*inputPath}, fs.Args()...)...)
+	if err != nil {
+		return err
+	}
+
+	names := []string{*processorName}
+	if *processorName == "all" {
+		names = names[:0]
+		for _, name := range processors.Names() {
+			if name != "naive" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	baseline, err := processors.New("naive", cfg)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	want, err := processors.ProcessFiles(ctx, baseline, paths, false)
+	if err != nil {
+		return fmt.Errorf("naive: %w", err)
+	}
+	log.Printf("naive: %d rows of %d stations in %s\n", want.Rows, len(want.Measures), time.Since(start))
+
+	failed := 0
+	for _, name := range names {
+		processor, err := processors.New(name, cfg)
+		if err != nil {
+			return err
+		}
+
+		start := time.Now()
+		got, err := processors.ProcessFiles(ctx, processor, paths, false)
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			failed++
+			continue
+		}
+
+		diffs := types.Compare(want, got)
+		for _, d := range diffs {
+			fmt.Printf("%s: %s\n", name, d)
+		}
+
+		if len(diffs) == 0 && got.String() != processors.NaiveString(want) {
+			fmt.Printf("%s: same aggregates as naive but a different output\n", name)
+			failed++
+			continue
+		}
+
+		if len(diffs) > 0 {
+			fmt.Printf("%s: %d of %d stations differ\n", name, len(diffs), len(want.Measures))
+			failed++
+			continue
+		}
+		fmt.Printf("%s: ok, %d rows of %d stations in %s\n", name, got.Rows, len(got.Measures), time.Since(start))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d processors disagree with naive", failed, len(names))
+	}
+
+	return nil
+}